@@ -0,0 +1,197 @@
+// S3 uploader, enhance the upload form rendered by RenderUploaderHtml.
+// Without JavaScript the form is submitted as a plain form.
+(function () {
+  "use strict";
+
+  if (!window.XMLHttpRequest || !window.FormData || !document.querySelectorAll) {
+    return;
+  }
+
+  Array.prototype.forEach.call(document.querySelectorAll("[data-s3-uploader]"), setupUploader);
+
+  function setupUploader(uploader) {
+    var form = getForm(uploader);
+    var fileInput = uploader.querySelector("input[type=file]");
+    if (!form || !fileInput) {
+      return;
+    }
+
+    var progress = uploader.querySelector("progress");
+    var status = uploader.querySelector("[data-s3-status]");
+    var minSize = parseInt(uploader.getAttribute("data-min-size") || "0", 10);
+    var maxSize = parseInt(uploader.getAttribute("data-max-size") || "0", 10);
+    var contentTypes = (uploader.getAttribute("data-content-types") || "").split(",").filter(Boolean);
+    var contentTypePrefix = uploader.getAttribute("data-content-type-prefix") || "";
+    var selectedFile = null;
+
+    uploader.className += " s3-uploader-enhanced";
+
+    function setStatus(message, isError) {
+      status.textContent = message;
+      status.className = isError ? "s3-uploader-error" : "";
+    }
+
+    // the same checks as the policy conditions, S3 still check the upload
+    function checkFile(file) {
+      if (maxSize > 0 && file.size > maxSize) {
+        return "File is too large, the maximum size is " + formatSize(maxSize) + ".";
+      }
+
+      if (file.size < minSize) {
+        return "File is too small, the minimum size is " + formatSize(minSize) + ".";
+      }
+
+      if (contentTypes.length > 0 && contentTypes.indexOf(file.type) < 0) {
+        return "File type " + (file.type || "unknown") + " is not allowed, allowed " + contentTypes.join(", ") + ".";
+      }
+
+      if (contentTypePrefix && file.type.indexOf(contentTypePrefix) !== 0) {
+        return "File type " + (file.type || "unknown") + " is not allowed, allowed " + contentTypePrefix + "*.";
+      }
+
+      return "";
+    }
+
+    function selectFile(file) {
+      selectedFile = null;
+      if (!file) {
+        setStatus("", false);
+        return;
+      }
+
+      var error = checkFile(file);
+      if (error) {
+        setStatus(error, true);
+        return;
+      }
+
+      selectedFile = file;
+      setStatus(file.name + " (" + formatSize(file.size) + ")", false);
+    }
+
+    fileInput.addEventListener("change", function () {
+      selectFile(fileInput.files && fileInput.files[0]);
+    });
+
+    uploader.addEventListener("dragover", function (event) {
+      event.preventDefault();
+      uploader.classList.add("s3-uploader-dragover");
+    });
+
+    uploader.addEventListener("dragleave", function () {
+      uploader.classList.remove("s3-uploader-dragover");
+    });
+
+    uploader.addEventListener("drop", function (event) {
+      event.preventDefault();
+      uploader.classList.remove("s3-uploader-dragover");
+      selectFile(event.dataTransfer.files && event.dataTransfer.files[0]);
+    });
+
+    form.addEventListener("submit", function (event) {
+      event.preventDefault();
+      if (!selectedFile) {
+        setStatus(status.textContent || "Select a file to upload.", true);
+        return;
+      }
+
+      var data = new FormData();
+      Array.prototype.forEach.call(form.querySelectorAll("input[type=hidden]"), function (input) {
+        var value = input.value;
+
+        // starts-with Content-Type only has the prefix, use the file type
+        if (input.name.toLowerCase() === "content-type" && contentTypePrefix && selectedFile.type) {
+          value = selectedFile.type;
+        }
+
+        data.append(input.name, value);
+      });
+
+      // S3 ignore the fields after the file, the file must be the last field
+      data.append(fileInput.name, selectedFile);
+
+      var xhr = new XMLHttpRequest();
+      xhr.open("POST", form.action);
+      xhr.upload.addEventListener("progress", function (progressEvent) {
+        if (progressEvent.lengthComputable) {
+          progress.max = progressEvent.total;
+          progress.value = progressEvent.loaded;
+        }
+      });
+
+      xhr.addEventListener("load", function () {
+        var result = parseResponse(xhr);
+        setStatus(result.ok ? "Upload complete." : result.message, !result.ok);
+        dispatchResult(form, result);
+      });
+
+      xhr.addEventListener("error", function () {
+        var result = {ok: false, status: 0, code: "NetworkError", message: "Upload failed, network error."};
+        setStatus(result.message, true);
+        dispatchResult(form, result);
+      });
+
+      progress.hidden = false;
+      progress.value = 0;
+      setStatus("Uploading " + selectedFile.name + "...", false);
+      xhr.send(data);
+    });
+  }
+
+  function getForm(element) {
+    while (element && element.tagName !== "FORM") {
+      element = element.parentNode;
+    }
+
+    return element;
+  }
+
+  function getXmlValue(xml, name) {
+    var element = xml && xml.getElementsByTagName(name)[0];
+    return element ? element.textContent : "";
+  }
+
+  // parse the PostResponse of success_action_status 201, or the S3 error document
+  function parseResponse(xhr) {
+    var xml = null;
+    if (xhr.responseText) {
+      xml = new DOMParser().parseFromString(xhr.responseText, "application/xml");
+    }
+
+    if (xhr.status >= 200 && xhr.status < 300) {
+      return {
+        ok: true,
+        status: xhr.status,
+        location: getXmlValue(xml, "Location"),
+        bucket: getXmlValue(xml, "Bucket"),
+        key: getXmlValue(xml, "Key"),
+        etag: getXmlValue(xml, "ETag")
+      };
+    }
+
+    return {
+      ok: false,
+      status: xhr.status,
+      code: getXmlValue(xml, "Code"),
+      message: getXmlValue(xml, "Message") || "Upload failed with status " + xhr.status + "."
+    };
+  }
+
+  // the result is sent as "s3upload" event of the form
+  function dispatchResult(form, result) {
+    var event = document.createEvent("CustomEvent");
+    event.initCustomEvent("s3upload", true, false, result);
+    form.dispatchEvent(event);
+  }
+
+  function formatSize(size) {
+    var units = ["B", "KiB", "MiB", "GiB"];
+    var unit = 0;
+    while (size >= 1024 && unit < units.length - 1) {
+      size /= 1024;
+      unit++;
+    }
+
+    return (unit === 0 ? size : size.toFixed(1)) + " " + units[unit];
+  }
+})();
@@ -0,0 +1,109 @@
+package s3Presign
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// BatchItem per-file overrides of the template policy, zero value fields are not changed
+type BatchItem struct {
+	Key         string            // "eq" key condition
+	ContentType string            // "eq" Content-Type condition
+	MinSize     uint64            // content-length-range, only used when MaxSize more than 0
+	MaxSize     uint64            // content-length-range
+	XAmzMeta    map[string]string // "eq" x-amz-meta-* conditions
+}
+
+type BatchResult struct {
+	Policy    string
+	Signature string
+	Forms     Forms
+	Err       error
+}
+
+// GenerateBatch generate a policy for every item from the template, using up to workers goroutines
+// (runtime.NumCPU() if workers is 0 or less). The results are in the same order as the items.
+// All policies use the same signing date, so the signing key is derived once.
+// The template is not changed.
+func GenerateBatch(template *BaseS3Policy, items []BatchItem, workers int) []BatchResult {
+	results := make([]BatchResult, len(items))
+	if err := template.AwsConfig.Validate(); err != nil {
+		for idx := range results {
+			results[idx].Err = err
+		}
+
+		return results
+	}
+
+	// stamp the signing date once, and set it manually so the clones are not re-stamped
+	base := template.Clone()
+	if err := base.stampDate(); err != nil {
+		for idx := range results {
+			results[idx].Err = err
+		}
+
+		return results
+	}
+
+	base.stampedDate = time.Time{}
+	base.getSigningKey()
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	jobs := make(chan int)
+	var waitGroup sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for idx := range jobs {
+				results[idx] = generateBatchItem(base, items[idx])
+			}
+		}()
+	}
+
+	for idx := range items {
+		jobs <- idx
+	}
+
+	close(jobs)
+	waitGroup.Wait()
+	return results
+}
+
+func generateBatchItem(template *BaseS3Policy, item BatchItem) (result BatchResult) {
+	// setters panic on invalid condition, return it as the item error
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = BatchResult{Err: fmt.Errorf("%v", recovered)}
+		}
+	}()
+
+	base := template.Clone()
+	if item.Key != "" {
+		base.SetKeyPolicy(ConditionMatchingExactMatch, item.Key)
+	}
+
+	if item.ContentType != "" {
+		base.SetContentTypePolicy(ConditionMatchingExactMatch, item.ContentType)
+	}
+
+	if item.MaxSize > 0 {
+		base.SetContentLengthPolicy(item.MinSize, item.MaxSize)
+	}
+
+	for key, value := range item.XAmzMeta {
+		base.SetXAmzMeta(key, ConditionMatchingExactMatch, value)
+	}
+
+	result.Policy, result.Signature, result.Forms, result.Err = base.GeneratePolicyWithError()
+	return result
+}
@@ -0,0 +1,70 @@
+package s3Presign
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGenerateBatch(t *testing.T) {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	template := NewS3Policy(defaultData.AwsConfig, WithClock(clock))
+	template.SetContentLengthPolicy(1, 10485760)
+	template.SetXAmzMeta("tenant", ConditionMatchingExactMatch, "acme")
+
+	var items []BatchItem
+	for i := 0; i < 50; i++ {
+		items = append(items, BatchItem{
+			Key:         fmt.Sprintf("gallery/%d.jpeg", i),
+			ContentType: "image/jpeg",
+			MaxSize:     1048576,
+			XAmzMeta:    map[string]string{"index": fmt.Sprint(i)},
+		})
+	}
+
+	items[10].ContentType = "image/png"                 // doesn't match the key extension
+	items[20].XAmzMeta = map[string]string{"": "value"} // invalid metadata name
+
+	results := GenerateBatch(template, items, 4)
+	if len(results) != len(items) {
+		t.Fatalf("batch should have %d results not %d", len(items), len(results))
+	}
+
+	for idx, result := range results {
+		if idx == 10 || idx == 20 {
+			if result.Err == nil {
+				t.Errorf("item %d should return error", idx)
+			}
+
+			continue
+		}
+
+		if result.Err != nil {
+			t.Errorf("item %d failed: %s", idx, result.Err.Error())
+			continue
+		}
+
+		if !hasFormValue(result.Forms, "key", items[idx].Key) || !hasFormValue(result.Forms, "x-amz-meta-index", fmt.Sprint(idx)) ||
+			!hasFormValue(result.Forms, "x-amz-meta-tenant", "acme") {
+			t.Errorf("item %d should have its own key and metadata", idx)
+		}
+
+		// the same signature as generated one by one
+		s3Policy := template.Clone()
+		s3Policy.SetKeyPolicy(ConditionMatchingExactMatch, items[idx].Key)
+		s3Policy.SetContentTypePolicy(ConditionMatchingExactMatch, items[idx].ContentType)
+		s3Policy.SetContentLengthPolicy(0, items[idx].MaxSize)
+		s3Policy.SetXAmzMeta("index", ConditionMatchingExactMatch, fmt.Sprint(idx))
+		if _, signature, _, _ := s3Policy.GeneratePolicyWithError(); signature != result.Signature {
+			t.Errorf("item %d signature should be [%s] not [%s]", idx, signature, result.Signature)
+		}
+	}
+
+	if template.Policy.Key.ConditionUsed != "" {
+		t.Errorf("template should not be changed by the batch")
+	}
+}
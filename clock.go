@@ -0,0 +1,112 @@
+package s3Presign
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock source of the current time, used for the signing date
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc use a function as Clock
+type ClockFunc func() time.Time
+
+func (clock ClockFunc) Now() time.Time {
+	return clock()
+}
+
+// SystemClock Clock using time.Now
+var SystemClock Clock = ClockFunc(time.Now)
+
+const CredentialExpiryClamp = "clamp"
+const CredentialExpiryError = "error"
+
+// SetCredentialExpiryMode set what to do when the policy expiration is after AwsConfig.CredentialExpiry,
+// CredentialExpiryClamp change the expiration to the credential expiry, CredentialExpiryError return error on generation.
+func (base *BaseS3Policy) SetCredentialExpiryMode(mode string) *BaseS3Policy {
+	if mode != CredentialExpiryClamp && mode != CredentialExpiryError {
+		panic("credential expiry mode not found!")
+	}
+
+	base.CredentialExpiryMode = mode
+	return base
+}
+
+// SetExpiresIn set the policy expiration relative to the signing date,
+// the expiration is calculated again when the signing date is re-stamped on generation.
+func (base *BaseS3Policy) SetExpiresIn(expiresIn time.Duration) *BaseS3Policy {
+	base.ExpiresIn = expiresIn
+	base.ExpiredDate = base.Date.Add(expiresIn)
+	return base
+}
+
+// SetTTLBounds set the minimum and maximum time between the signing date and the policy expiration,
+// zero value means no bound.
+func (base *BaseS3Policy) SetTTLBounds(minTTL, maxTTL time.Duration) *BaseS3Policy {
+	base.MinTTL = minTTL
+	base.MaxTTL = maxTTL
+	return base
+}
+
+// re-stamp the signing date (if it's not changed manually) and relative expiration with the clock,
+// limit the expiration to the credential expiry, then validate the expiration. x-amz-date and x-amz-credential use the signing date.
+// It's called on the copy of the base used for one generation, so the limited expiration is not kept.
+func (base *BaseS3Policy) stampDate() error {
+	if base.Clock == nil {
+		base.Clock = SystemClock
+	}
+
+	if base.Date.Equal(base.stampedDate) {
+		base.Date = base.Clock.Now()
+		base.stampedDate = base.Date
+	}
+
+	if base.ExpiresIn > 0 {
+		base.ExpiredDate = base.Date.Add(base.ExpiresIn)
+	}
+
+	expiredDate, err := base.getCredentialExpiry(base.ExpiredDate)
+	if err != nil {
+		return err
+	}
+
+	base.ExpiredDate = expiredDate
+
+	if !base.ExpiredDate.After(base.Date) {
+		return fmt.Errorf("policy expiration [%s] must be after signing date [%s]",
+			base.ExpiredDate.UTC().Format(ExpirationFormat), base.Date.UTC().Format(ExpirationFormat))
+	}
+
+	ttl := base.ExpiredDate.Sub(base.Date)
+	if base.MinTTL > 0 && ttl < base.MinTTL {
+		return fmt.Errorf("policy is valid for %s, minimum is %s", ttl, base.MinTTL)
+	}
+
+	if base.MaxTTL > 0 && ttl > base.MaxTTL {
+		return fmt.Errorf("policy is valid for %s, maximum is %s", ttl, base.MaxTTL)
+	}
+
+	return nil
+}
+
+// the policy signed with temporary credentials can't be used after the credentials expired,
+// S3 will reject the upload with signature error. Return the expiration limited to the credential expiry.
+func (base *BaseS3Policy) getCredentialExpiry(expiredDate time.Time) (time.Time, error) {
+	credentialExpiry := base.AwsConfig.CredentialExpiry
+	if credentialExpiry.IsZero() || !expiredDate.After(credentialExpiry) {
+		return expiredDate, nil
+	}
+
+	if !credentialExpiry.After(base.Date) {
+		return time.Time{}, fmt.Errorf("credentials expired at [%s]", credentialExpiry.UTC().Format(ExpirationFormat))
+	}
+
+	if base.CredentialExpiryMode == CredentialExpiryError {
+		return time.Time{}, fmt.Errorf("policy expiration [%s] is after credential expiry [%s]",
+			expiredDate.UTC().Format(ExpirationFormat), credentialExpiry.UTC().Format(ExpirationFormat))
+	}
+
+	return credentialExpiry, nil
+}
@@ -0,0 +1,143 @@
+package s3Presign
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	defaultData := getDefaultData()
+
+	timeNow := defaultData.DateCreated
+	clock := ClockFunc(func() time.Time {
+		return timeNow
+	})
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig, WithClock(clock), WithTTLBounds(time.Minute, time.Hour))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetExpiresIn(30 * time.Minute)
+	if !s3PolicyBase.Date.Equal(timeNow) || !s3PolicyBase.ExpiredDate.Equal(timeNow.Add(30*time.Minute)) {
+		t.Errorf("date and expiration should use the clock, got [%s] and [%s]", s3PolicyBase.Date, s3PolicyBase.ExpiredDate)
+	}
+
+	// the signing date is re-stamped on generation
+	timeNow = timeNow.Add(24 * time.Hour)
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if !hasFormValue(formsData, "x-amz-date", "20151230T000000Z") ||
+		!hasFormValue(formsData, "x-amz-credential", AWSAccessKeyId+"/20151230/us-east-1/s3/aws4_request") {
+		t.Errorf("x-amz-date and x-amz-credential should be re-stamped, got %v", formsData.FormData)
+	}
+
+	if !formsData.Expiration.Equal(timeNow.Add(30 * time.Minute)) {
+		t.Errorf("relative expiration should be calculated from the new signing date, got [%s]", formsData.Expiration)
+	}
+
+	s3PolicyBase.SetExpiresIn(2 * time.Hour)
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("expiration more than maximum TTL should return error")
+	}
+
+	s3PolicyBase.SetExpiresIn(time.Second)
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("expiration less than minimum TTL should return error")
+	}
+
+	// date set manually is not re-stamped
+	s3PolicyBase = NewS3Policy(defaultData.AwsConfig, WithClock(clock))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.Date = defaultData.DateCreated
+	s3PolicyBase.SetExpirationDate(defaultData.DateCreated.Add(-time.Minute))
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("expiration before signing date should return error")
+	}
+
+	if !s3PolicyBase.Date.Equal(defaultData.DateCreated) {
+		t.Errorf("date set manually should not be re-stamped, got [%s]", s3PolicyBase.Date)
+	}
+}
+
+func TestCredentialExpiry(t *testing.T) {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	awsConfig := defaultData.AwsConfig
+	awsConfig.AwsSessionToken = "session-token"
+	awsConfig.CredentialExpiry = defaultData.DateCreated.Add(5 * time.Minute)
+
+	s3PolicyBase := NewS3Policy(awsConfig, WithClock(clock))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetExpiresIn(time.Hour)
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if !formsData.Expiration.Equal(awsConfig.CredentialExpiry) {
+		t.Errorf("expiration should be clamped to [%s] not [%s]", awsConfig.CredentialExpiry, formsData.Expiration)
+	}
+
+	if !hasFormValue(formsData, "x-amz-security-token", "session-token") {
+		t.Errorf("session token should be added as x-amz-security-token")
+	}
+
+	s3PolicyBase.SetCredentialExpiryMode(CredentialExpiryError)
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("expiration after credential expiry should return error")
+	}
+
+	awsConfig.CredentialExpiry = defaultData.DateCreated
+	s3PolicyBase = NewS3Policy(awsConfig, WithClock(clock))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("expired credentials should return error")
+	}
+}
+
+func TestCredentialRefresh(t *testing.T) {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	awsConfig := defaultData.AwsConfig
+	awsConfig.AwsSessionToken = "token-a"
+	awsConfig.CredentialExpiry = defaultData.DateCreated.Add(5 * time.Minute)
+
+	s3PolicyBase := NewS3Policy(awsConfig, WithClock(clock))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetExpirationDate(defaultData.DateCreated.Add(time.Hour))
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if !hasFormValue(formsData, "x-amz-security-token", "token-a") || !formsData.Expiration.Equal(awsConfig.CredentialExpiry) {
+		t.Errorf("policy should use token-a and expire at [%s]: %s %+v", awsConfig.CredentialExpiry, formsData.Expiration, formsData.FormData)
+	}
+
+	// the refreshed credentials are used by the next generation
+	s3PolicyBase.AwsConfig.AwsSessionToken = "token-b"
+	s3PolicyBase.AwsConfig.CredentialExpiry = defaultData.DateCreated.Add(2 * time.Hour)
+	_, _, formsData, err = s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if !hasFormValue(formsData, "x-amz-security-token", "token-b") || hasFormValue(formsData, "x-amz-security-token", "token-a") {
+		t.Errorf("policy should use the refreshed token: %+v", formsData.FormData)
+	}
+
+	if !formsData.Expiration.Equal(defaultData.DateCreated.Add(time.Hour)) {
+		t.Errorf("expiration should be [%s] after the refresh not [%s]", defaultData.DateCreated.Add(time.Hour), formsData.Expiration)
+	}
+
+	if _, err = s3PolicyBase.State(); err != nil {
+		t.Errorf("generated policy should be stored: %s", err.Error())
+	}
+}
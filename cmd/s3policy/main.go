@@ -0,0 +1,125 @@
+// Command s3policy explain and compare S3 POST policies.
+//
+//	s3policy explain [-markdown] <policy>
+//	s3policy diff [-markdown] <policy-a> <policy-b>
+//
+// The policy is a file path, "-" for stdin, or the policy itself, as JSON document
+// or the base64 encoded "policy" form field. diff exit with status 1 if the policies are different.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	s3Presign "github.com/fari-99/aws-presignpost-s3-go"
+)
+
+const usage = `usage:
+  s3policy explain [-markdown] <policy>
+  s3policy diff [-markdown] <policy-a> <policy-b>
+`
+
+// errPolicyChanged returned by diff if the policies are different
+var errPolicyChanged = errors.New("policies are different")
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if errors.Is(err, errPolicyChanged) {
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	flagSet := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	isMarkdown := flagSet.Bool("markdown", false, "output Markdown")
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%s\n%s", err.Error(), usage)
+	}
+
+	format := s3Presign.ExplainFormatText
+	if *isMarkdown {
+		format = s3Presign.ExplainFormatMarkdown
+	}
+
+	switch {
+	case args[0] == "explain" && flagSet.NArg() == 1:
+		document, err := readPolicyDocument(flagSet.Arg(0), stdin)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(stdout, document.Explain(format))
+		return err
+	case args[0] == "diff" && flagSet.NArg() == 2:
+		documentA, err := readPolicyDocument(flagSet.Arg(0), stdin)
+		if err != nil {
+			return err
+		}
+
+		documentB, err := readPolicyDocument(flagSet.Arg(1), stdin)
+		if err != nil {
+			return err
+		}
+
+		diff := s3Presign.DiffPolicyDocuments(documentA, documentB)
+		if diff.IsEmpty() {
+			return nil
+		}
+
+		output := diff.String()
+		if *isMarkdown {
+			output = diff.Markdown()
+		}
+
+		if _, err = io.WriteString(stdout, output); err != nil {
+			return err
+		}
+
+		return errPolicyChanged
+	}
+
+	return errors.New(usage)
+}
+
+// read the policy from file, stdin if the argument is "-", or the argument itself if it's not a file
+func readPolicyDocument(argument string, stdin io.Reader) (s3Presign.PolicyDocument, error) {
+	var data []byte
+	var err error
+	switch {
+	case argument == "-":
+		data, err = io.ReadAll(stdin)
+	case isFile(argument):
+		data, err = os.ReadFile(argument)
+	default:
+		data = []byte(argument)
+	}
+
+	if err != nil {
+		return s3Presign.PolicyDocument{}, err
+	}
+
+	document, err := s3Presign.ParsePolicyDocument(data)
+	if err != nil {
+		return s3Presign.PolicyDocument{}, fmt.Errorf("%s: %w", argument, err)
+	}
+
+	return document, nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
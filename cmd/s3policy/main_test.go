@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `{"expiration":"2015-12-30T12:00:00.000Z","conditions":[{"bucket":"sigv4examplebucket"},` +
+	`["starts-with","$key","user/user1/"],{"acl":"public-read"},["content-length-range",1,10485760]]}`
+
+func TestExplain(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	_ = os.WriteFile(policyPath, []byte(testPolicy), 0o600)
+
+	expected := `Upload to bucket sigv4examplebucket, expires 2015-12-30 12:00 UTC
+- Bucket must be sigv4examplebucket
+- Key must start with user/user1/
+- ACL must be public-read
+- Size must be between 1 B and 10 MiB
+`
+
+	// file, stdin and base64 argument
+	for _, argument := range []string{policyPath, "-", base64.StdEncoding.EncodeToString([]byte(testPolicy))} {
+		stdout := &bytes.Buffer{}
+		if err := run([]string{"explain", argument}, strings.NewReader(testPolicy), stdout); err != nil {
+			t.Fatalf("explain failed: %s", err.Error())
+		}
+
+		if stdout.String() != expected {
+			t.Errorf("explain should be:\n%s\nnot:\n%s", expected, stdout.String())
+		}
+	}
+
+	stdout := &bytes.Buffer{}
+	if err := run([]string{"explain", "-markdown", policyPath}, nil, stdout); err != nil {
+		t.Fatalf("explain failed: %s", err.Error())
+	}
+
+	if !strings.Contains(stdout.String(), "| acl | must be `public-read` |") {
+		t.Errorf("explain should be markdown:\n%s", stdout.String())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	changedPolicy := strings.Replace(testPolicy, "public-read", "private", 1)
+
+	stdout := &bytes.Buffer{}
+	if err := run([]string{"diff", testPolicy, testPolicy}, nil, stdout); err != nil || stdout.Len() != 0 {
+		t.Errorf("the same policies should not have diff: %v %s", err, stdout.String())
+	}
+
+	err := run([]string{"diff", testPolicy, changedPolicy}, nil, stdout)
+	if !errors.Is(err, errPolicyChanged) {
+		t.Errorf("different policies should return errPolicyChanged, got %v", err)
+	}
+
+	if stdout.String() != "~ acl must be public-read -> must be private\n" {
+		t.Errorf("invalid diff: %s", stdout.String())
+	}
+
+	for _, args := range [][]string{nil, {"explain"}, {"diff", testPolicy}, {"unknown", testPolicy}, {"explain", "-x", testPolicy}, {"explain", "invalid"}} {
+		if err = run(args, nil, &bytes.Buffer{}); err == nil || errors.Is(err, errPolicyChanged) {
+			t.Errorf("%v should return error", args)
+		}
+	}
+}
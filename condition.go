@@ -0,0 +1,78 @@
+package s3Presign
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Condition a single condition on any form field, added with AddCondition or AddRange.
+// Conditions are kept in order and can be used on the same field more than once,
+// ex: ["starts-with", "$key", "users/42/"] together with {"key": "users/42/avatar.png"}
+type Condition struct {
+	Field         string
+	ConditionUsed string
+
+	PolicyValue      string
+	PolicyStartRange uint64
+	PolicyStopRange  uint64
+}
+
+// AddCondition add "eq" or "starts-with" condition for the field, the field can be any form field
+// (ex: "key", "x-amz-meta-tenant", "x-ignore-tracking"), with or without "$" prefix.
+// The field must be registered in the policy field registry (see RegisterPolicyField),
+// and the condition matching type must be allowed for the field.
+// x-amz-meta-* values are validated and encoded like SetXAmzMeta, and GeneratePolicyWithError return MergeConflictError
+// if the condition conflicts with the other conditions of the field, ex: "eq" with different value.
+func (base *BaseS3Policy) AddCondition(field, conditionMatch, value string) *BaseS3Policy {
+	field = strings.TrimPrefix(field, "$")
+	if field == "" {
+		panic("condition field can't be empty")
+	}
+
+	if conditionMatch == ConditionSpecifyingRange {
+		panic("use AddRange for content-length-range condition")
+	}
+
+	canBeUsed := checkConditions(getFieldConditions(field), conditionMatch)
+	if !canBeUsed {
+		panic("condition matching type can't be used")
+	}
+
+	base.Policy.Conditions = append(base.Policy.Conditions, Condition{
+		Field:         field,
+		ConditionUsed: conditionMatch,
+		PolicyValue:   value,
+	})
+	return base
+}
+
+// AddRange add range condition for the field, S3 only support range for "content-length-range".
+func (base *BaseS3Policy) AddRange(field string, min, max uint64) *BaseS3Policy {
+	field = strings.TrimPrefix(field, "$")
+	canBeUsed := checkConditions(getFieldConditions(field), ConditionSpecifyingRange)
+	if !canBeUsed {
+		panic("condition matching type can't be used")
+	}
+
+	if min > max {
+		panic("range minimum can't be more than maximum")
+	}
+
+	base.Policy.Conditions = append(base.Policy.Conditions, Condition{
+		Field:            field,
+		ConditionUsed:    ConditionSpecifyingRange,
+		PolicyStartRange: min,
+		PolicyStopRange:  max,
+	})
+	return base
+}
+
+// get condition matching allowed for the field from the policy field registry
+func getFieldConditions(field string) ConditionMatching {
+	policyField, ok := GetPolicyField(field)
+	if !ok {
+		panic(fmt.Sprintf("field [%s] is not registered, use RegisterPolicyField to add it", field))
+	}
+
+	return policyField.Conditions
+}
@@ -0,0 +1,130 @@
+package s3Presign
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAddCondition(t *testing.T) {
+	defaultData := getDefaultData()
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.Date = defaultData.DateCreated
+	s3PolicyBase.SetExpirationDate(defaultData.TimeExpired)
+
+	s3PolicyBase.AddCondition("$key", ConditionMatchingStartWith, "users/42/")
+	s3PolicyBase.AddCondition("key", ConditionMatchingExactMatch, "users/42/avatar.png")
+	s3PolicyBase.AddCondition("$x-ignore-tracking", ConditionMatchingStartWith, "")
+	s3PolicyBase.AddCondition("x-amz-meta-tenant", ConditionMatchingExactMatch, "acme")
+	s3PolicyBase.AddRange("content-length-range", 1, 1048576)
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, "private")
+
+	encodedPolicy, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	policyDecoded, _ := base64.StdEncoding.DecodeString(encodedPolicy)
+	var policyDocument struct {
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	_ = json.Unmarshal(policyDecoded, &policyDocument)
+
+	testConditions := []string{
+		`["starts-with","$key","users/42/"]`,
+		`{"key":"users/42/avatar.png"}`,
+		`["starts-with","$x-ignore-tracking",""]`,
+		`{"x-amz-meta-tenant":"acme"}`,
+		`["content-length-range",1,1048576]`,
+	}
+
+	// conditions added with AddCondition are added in order after the Policy fields
+	conditions := policyDocument.Conditions[len(policyDocument.Conditions)-len(testConditions):]
+	for idx, testCondition := range testConditions {
+		if string(conditions[idx]) != testCondition {
+			t.Errorf("condition %d should be %s not %s", idx, testCondition, string(conditions[idx]))
+		}
+	}
+
+	formValues := map[string]int{}
+	for _, value := range formsData.FormData {
+		formValues[value.FormName]++
+		if value.FormName == "key" && value.FormValue != "users/42/avatar.png" {
+			t.Errorf("key form value should be [users/42/avatar.png] not [%s]", value.FormValue)
+		}
+	}
+
+	if formValues["key"] != 1 || formValues["acl"] != 1 || formValues["x-amz-meta-tenant"] != 1 {
+		t.Errorf("key, acl and x-amz-meta-tenant form value should be added once, got %v", formValues)
+	}
+
+	if _, ok := formValues["x-ignore-tracking"]; ok {
+		t.Errorf("x-ignore-tracking is not a valid form field")
+	}
+}
+
+func TestAddConditionXAmzMeta(t *testing.T) {
+	defaultData := getDefaultData()
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "test.txt")
+	s3PolicyBase.SetXAmzMetaEncoding(true)
+	s3PolicyBase.AddCondition("x-amz-meta-tenant", ConditionMatchingExactMatch, "café")
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if !hasFormValue(formsData, "x-amz-meta-tenant", encodeXAmzMetaValue("café")) {
+		t.Errorf("x-amz-meta condition value should be encoded: %+v", formsData.FormData)
+	}
+
+	if s3PolicyBase.Policy.Conditions[0].PolicyValue != "café" {
+		t.Errorf("condition value of the base should not be encoded")
+	}
+
+	// the condition is validated the same as SetXAmzMeta
+	invalidConditions := map[string]string{
+		"x-amz-meta-bad name": "value",
+		"x-amz-meta-large":    strings.Repeat("a", MaxXAmzMetaSize),
+	}
+
+	for field, value := range invalidConditions {
+		s3PolicyBase = NewS3Policy(defaultData.AwsConfig)
+		s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "test.txt")
+		s3PolicyBase.AddCondition(field, ConditionMatchingExactMatch, value)
+		if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err == nil {
+			t.Errorf("condition [%s] should return error", field)
+		}
+	}
+
+	s3PolicyBase = NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "test.txt")
+	s3PolicyBase.AddCondition("x-amz-meta-tenant", ConditionMatchingExactMatch, "café")
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("non-ASCII value without encoding should return error")
+	}
+}
+
+func TestAddConditionConflict(t *testing.T) {
+	defaultData := getDefaultData()
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "users/42/avatar.png")
+	s3PolicyBase.SetXAmzMeta("tenant", ConditionMatchingExactMatch, "acme")
+	s3PolicyBase.AddCondition("key", ConditionMatchingStartWith, "users/43/")
+	s3PolicyBase.AddCondition("x-amz-meta-tenant", ConditionMatchingExactMatch, "other")
+
+	var conflictError MergeConflictError
+	_, _, _, err := s3PolicyBase.GeneratePolicyWithError()
+	if !errors.As(err, &conflictError) || len(conflictError.Conflicts) != 2 {
+		t.Fatalf("conflicting conditions should return MergeConflictError with 2 conflicts, got %v", err)
+	}
+
+	if conflictError.Conflicts[0].Field != "key" || conflictError.Conflicts[1].Field != "x-amz-meta-tenant" {
+		t.Errorf("conflicts should be key and x-amz-meta-tenant: %+v", conflictError.Conflicts)
+	}
+}
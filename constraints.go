@@ -0,0 +1,178 @@
+package s3Presign
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// JSONSchemaDraft schema version of the document generated by UploadConstraints.JSONSchema
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// UploadConstraints the upload constraints from the policy conditions, used by the client to check the file before upload.
+// All conditions must be passed, so the strictest constraints are used.
+type UploadConstraints struct {
+	ContentTypes      []string          `json:"contentTypes,omitempty"`      // from "eq" Content-Type condition or the allowed content types
+	ContentTypePrefix string            `json:"contentTypePrefix,omitempty"` // from "starts-with" Content-Type condition
+	MinSize           uint64            `json:"minSize"`
+	MaxSize           uint64            `json:"maxSize,omitempty"` // 0 if the policy has no content-length-range
+	Key               string            `json:"key,omitempty"`     // from "eq" key condition
+	KeyPrefix         string            `json:"keyPrefix,omitempty"`
+	Metadata          []FieldConstraint `json:"metadata,omitempty"` // x-amz-meta-* conditions, all are required
+	Expiration        time.Time         `json:"expiration"`
+}
+
+// FieldConstraint condition of a form field, value is the prefix for "starts-with"
+type FieldConstraint struct {
+	Name      string `json:"name"`
+	Condition string `json:"condition"`
+	Value     string `json:"value"`
+}
+
+// GetUploadConstraints get the upload constraints from the policy conditions,
+// allowedContentTypes (see AllowContentTypes) limit the content types to the list instead of the Content-Type prefix.
+func GetUploadConstraints(conditions []Condition, expiration time.Time, allowedContentTypes ...string) UploadConstraints {
+	constraints := UploadConstraints{Expiration: expiration}
+	for _, condition := range conditions {
+		switch {
+		case condition.ConditionUsed == ConditionSpecifyingRange:
+			if condition.PolicyStartRange > constraints.MinSize {
+				constraints.MinSize = condition.PolicyStartRange
+			}
+
+			if constraints.MaxSize == 0 || condition.PolicyStopRange < constraints.MaxSize {
+				constraints.MaxSize = condition.PolicyStopRange
+			}
+		case strings.EqualFold(condition.Field, "Content-Type"):
+			if condition.ConditionUsed == ConditionMatchingExactMatch {
+				constraints.ContentTypes = []string{condition.PolicyValue}
+			} else if len(condition.PolicyValue) > len(constraints.ContentTypePrefix) {
+				constraints.ContentTypePrefix = condition.PolicyValue
+			}
+		case condition.Field == "key":
+			if condition.ConditionUsed == ConditionMatchingExactMatch {
+				constraints.Key = condition.PolicyValue
+			} else if len(condition.PolicyValue) > len(constraints.KeyPrefix) {
+				constraints.KeyPrefix = condition.PolicyValue
+			}
+		case strings.HasPrefix(strings.ToLower(condition.Field), "x-amz-meta-"):
+			constraints.Metadata = append(constraints.Metadata, FieldConstraint{
+				Name:      condition.Field,
+				Condition: condition.ConditionUsed,
+				Value:     condition.PolicyValue,
+			})
+		}
+	}
+
+	if len(allowedContentTypes) > 0 {
+		contentTypes := make([]string, 0, len(allowedContentTypes))
+		for _, contentType := range allowedContentTypes {
+			if !strings.HasPrefix(contentType, constraints.ContentTypePrefix) {
+				continue
+			}
+
+			if len(constraints.ContentTypes) == 0 || inStrings(constraints.ContentTypes, contentType) {
+				contentTypes = append(contentTypes, contentType)
+			}
+		}
+
+		constraints.ContentTypes = contentTypes
+	}
+
+	return constraints
+}
+
+// Constraints get the upload constraints of the signed policy conditions and the allowed content types
+func (forms Forms) Constraints() UploadConstraints {
+	return GetUploadConstraints(forms.Conditions, forms.Expiration, forms.AllowedContentTypes...)
+}
+
+// JSONSchema get JSON Schema of the upload: {"key", "contentType", "size", "metadata"},
+// the expiration is added as "x-expiration" annotation.
+func (constraints UploadConstraints) JSONSchema() ([]byte, error) {
+	properties := map[string]interface{}{
+		"key":         getStringSchema(ConditionMatchingStartWith, constraints.KeyPrefix),
+		"contentType": getStringSchema(ConditionMatchingStartWith, constraints.ContentTypePrefix),
+	}
+
+	if constraints.Key != "" {
+		properties["key"] = getStringSchema(ConditionMatchingExactMatch, constraints.Key)
+	}
+
+	if len(constraints.ContentTypes) > 0 {
+		properties["contentType"] = map[string]interface{}{"type": "string", "enum": constraints.ContentTypes}
+	}
+
+	size := map[string]interface{}{"type": "integer", "minimum": constraints.MinSize}
+	if constraints.MaxSize > 0 {
+		size["maximum"] = constraints.MaxSize
+	}
+
+	properties["size"] = size
+
+	required := []string{"key", "size"}
+	if len(constraints.ContentTypes) > 0 || constraints.ContentTypePrefix != "" {
+		required = append(required, "contentType")
+	}
+
+	if len(constraints.Metadata) > 0 {
+		metadataProperties := make(map[string]interface{}, len(constraints.Metadata))
+		metadataRequired := make([]string, 0, len(constraints.Metadata))
+		for _, metadata := range constraints.Metadata {
+			metadataProperties[metadata.Name] = getStringSchema(metadata.Condition, metadata.Value)
+
+			if !inStrings(metadataRequired, metadata.Name) {
+				metadataRequired = append(metadataRequired, metadata.Name)
+			}
+		}
+
+		properties["metadata"] = map[string]interface{}{
+			"type":       "object",
+			"properties": metadataProperties,
+			"required":   metadataRequired,
+		}
+
+		required = append(required, "metadata")
+	}
+
+	schema := map[string]interface{}{
+		"$schema":    JSONSchemaDraft,
+		"title":      "S3 upload",
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+
+	if !constraints.Expiration.IsZero() {
+		schema["x-expiration"] = constraints.Expiration.UTC().Format(time.RFC3339)
+	}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// accept attribute of the file input, the browser only filter the selected files
+func (constraints UploadConstraints) getAccept() string {
+	if len(constraints.ContentTypes) > 0 {
+		return strings.Join(constraints.ContentTypes, ",")
+	}
+
+	if prefix := constraints.ContentTypePrefix; strings.HasSuffix(prefix, "/") && strings.Count(prefix, "/") == 1 {
+		return prefix + "*"
+	}
+
+	return ""
+}
+
+// schema of string matching the condition
+func getStringSchema(conditionUsed, value string) map[string]interface{} {
+	if conditionUsed == ConditionMatchingExactMatch {
+		return map[string]interface{}{"type": "string", "const": value}
+	}
+
+	if value != "" {
+		return map[string]interface{}{"type": "string", "pattern": "^" + regexp.QuoteMeta(value)}
+	}
+
+	return map[string]interface{}{"type": "string"}
+}
@@ -0,0 +1,146 @@
+package s3Presign
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetUploadConstraints(t *testing.T) {
+	expiration := time.Date(2015, 12, 30, 12, 0, 0, 0, time.UTC)
+	constraints := GetUploadConstraints([]Condition{
+		{Field: ConditionSpecifyingRange, ConditionUsed: ConditionSpecifyingRange, PolicyStartRange: 1, PolicyStopRange: 2048},
+		{Field: ConditionSpecifyingRange, ConditionUsed: ConditionSpecifyingRange, PolicyStartRange: 10, PolicyStopRange: 4096},
+		{Field: "content-type", ConditionUsed: ConditionMatchingStartWith, PolicyValue: "image/"},
+		{Field: "key", ConditionUsed: ConditionMatchingStartWith, PolicyValue: "user/"},
+		{Field: "key", ConditionUsed: ConditionMatchingStartWith, PolicyValue: "user/user1/"},
+		{Field: "x-amz-meta-uuid", ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "14365123651274"},
+		{Field: "x-amz-meta-tag", ConditionUsed: ConditionMatchingStartWith, PolicyValue: ""},
+		{Field: "acl", ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "private"},
+	}, expiration)
+
+	expected := UploadConstraints{
+		ContentTypePrefix: "image/",
+		MinSize:           10,
+		MaxSize:           2048,
+		KeyPrefix:         "user/user1/",
+		Metadata: []FieldConstraint{
+			{Name: "x-amz-meta-uuid", Condition: ConditionMatchingExactMatch, Value: "14365123651274"},
+			{Name: "x-amz-meta-tag", Condition: ConditionMatchingStartWith, Value: ""},
+		},
+		Expiration: expiration,
+	}
+
+	if !reflect.DeepEqual(constraints, expected) {
+		t.Errorf("constraints should be %+v not %+v", expected, constraints)
+	}
+
+	if constraints.getAccept() != "image/*" {
+		t.Errorf("accept should be image/* not %s", constraints.getAccept())
+	}
+
+	constraints = GetUploadConstraints([]Condition{{Field: "Content-Type", ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "image/png"}}, expiration)
+	if constraints.MaxSize != 0 || constraints.getAccept() != "image/png" {
+		t.Errorf("content type should be image/png without size limit, got %+v", constraints)
+	}
+
+	constraints = GetUploadConstraints([]Condition{{Field: "Content-Type", ConditionUsed: ConditionMatchingStartWith, PolicyValue: "image/pn"}}, expiration)
+	if constraints.getAccept() != "" {
+		t.Errorf("partial content type prefix should not be used as accept, got %s", constraints.getAccept())
+	}
+}
+
+func TestUploadConstraintsJSONSchema(t *testing.T) {
+	s3PolicyBase := getTestPolicy()
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "user/user1/")
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	output, err := formsData.Constraints().JSONSchema()
+	if err != nil {
+		t.Fatalf("failed to generate schema: %s", err.Error())
+	}
+
+	var schema map[string]interface{}
+	if err = json.Unmarshal(output, &schema); err != nil {
+		t.Fatalf("invalid schema: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"$schema": JSONSchemaDraft,
+		"title":   "S3 upload",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"key":         map[string]interface{}{"type": "string", "pattern": `^user/user1/`},
+			"contentType": map[string]interface{}{"type": "string", "enum": []interface{}{"image/jpeg"}},
+			"size":        map[string]interface{}{"type": "integer", "minimum": float64(0), "maximum": float64(10485760)},
+			"metadata": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"x-amz-meta-tag":   map[string]interface{}{"type": "string", "pattern": "^line\nbreak\ttab"},
+					"x-amz-meta-title": map[string]interface{}{"type": "string", "const": "=?UTF-8?b?Y2Fmw6k=?="},
+					"x-amz-meta-uuid":  map[string]interface{}{"type": "string", "const": "bc2035bf-72b6-4bad-9e1f-c6c8732ac1a4"},
+				},
+				"required": []interface{}{"x-amz-meta-tag", "x-amz-meta-title", "x-amz-meta-uuid"},
+			},
+		},
+		"required":     []interface{}{"key", "size", "contentType", "metadata"},
+		"x-expiration": "2015-12-30T12:00:00Z",
+	}
+
+	if !reflect.DeepEqual(schema, expected) {
+		t.Errorf("schema should be %v not %v", expected, schema)
+	}
+
+	// the constraints are returned alongside the form fields
+	output, _ = json.Marshal(formsData)
+	var document struct {
+		Fields      map[string]string `json:"fields"`
+		Constraints UploadConstraints `json:"constraints"`
+	}
+	_ = json.Unmarshal(output, &document)
+	if document.Fields["key"] != "user/user1/" || document.Constraints.KeyPrefix != "user/user1/" || document.Constraints.MaxSize != 10485760 {
+		t.Errorf("forms JSON should have the fields and constraints: %s", string(output))
+	}
+}
+
+func TestUploadConstraintsAllowedContentTypes(t *testing.T) {
+	s3PolicyBase := getTestPolicy()
+	s3PolicyBase.AllowContentTypes([]string{"image/png", "image/jpeg"})
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	// the policy only has the prefix, the constraints use the allowed list
+	constraints := formsData.Constraints()
+	if !reflect.DeepEqual(constraints.ContentTypes, []string{"image/png", "image/jpeg"}) || constraints.ContentTypePrefix != "image/" {
+		t.Errorf("content types should be the allowed content types, got %+v", constraints)
+	}
+
+	if constraints.getAccept() != "image/png,image/jpeg" {
+		t.Errorf("accept should be the allowed content types not %s", constraints.getAccept())
+	}
+
+	output, _ := constraints.JSONSchema()
+	var schema struct {
+		Properties struct {
+			ContentType struct {
+				Enum []string `json:"enum"`
+			} `json:"contentType"`
+		} `json:"properties"`
+	}
+	_ = json.Unmarshal(output, &schema)
+	if !reflect.DeepEqual(schema.Properties.ContentType.Enum, []string{"image/png", "image/jpeg"}) {
+		t.Errorf("schema content type should be enum of the allowed content types: %s", string(output))
+	}
+
+	// the "eq" condition limit the allowed list
+	constraints = GetUploadConstraints([]Condition{{Field: "Content-Type", ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "image/png"}}, time.Time{}, "image/png", "image/jpeg")
+	if !reflect.DeepEqual(constraints.ContentTypes, []string{"image/png"}) {
+		t.Errorf("content types should be image/png not %v", constraints.ContentTypes)
+	}
+}
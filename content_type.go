@@ -0,0 +1,193 @@
+package s3Presign
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+)
+
+// built-in MIME type to extensions table, the first extension is the preferred one.
+// we don't use mime.TypeByExtension because the result depends on the system mime files.
+var contentTypeExtensions = map[string][]string{
+	"application/gzip":         {".gz"},
+	"application/json":         {".json"},
+	"application/msword":       {".doc"},
+	"application/pdf":          {".pdf"},
+	"application/vnd.ms-excel": {".xls"},
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": {".pptx"},
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         {".xlsx"},
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   {".docx"},
+	"application/xml": {".xml"},
+	"application/zip": {".zip"},
+	"audio/aac":       {".aac"},
+	"audio/mpeg":      {".mp3"},
+	"audio/ogg":       {".ogg", ".oga"},
+	"audio/wav":       {".wav"},
+	"image/avif":      {".avif"},
+	"image/bmp":       {".bmp"},
+	"image/gif":       {".gif"},
+	"image/heic":      {".heic"},
+	"image/jpeg":      {".jpg", ".jpeg", ".jpe"},
+	"image/png":       {".png"},
+	"image/svg+xml":   {".svg"},
+	"image/tiff":      {".tif", ".tiff"},
+	"image/webp":      {".webp"},
+	"text/csv":        {".csv"},
+	"text/html":       {".html", ".htm"},
+	"text/markdown":   {".md"},
+	"text/plain":      {".txt"},
+	"video/mp4":       {".mp4", ".m4v"},
+	"video/mpeg":      {".mpeg", ".mpg"},
+	"video/quicktime": {".mov"},
+	"video/webm":      {".webm"},
+}
+
+var extensionContentType = func() map[string]string {
+	extensions := map[string]string{}
+	for contentType, values := range contentTypeExtensions {
+		for _, extension := range values {
+			extensions[extension] = contentType
+		}
+	}
+
+	return extensions
+}()
+
+// ContentTypeByExtension return the content type of the file extension (ex: ".png") from the built-in table,
+// or empty string if the extension is unknown.
+func ContentTypeByExtension(extension string) string {
+	return extensionContentType[strings.ToLower(extension)]
+}
+
+// ExtensionsByContentType return the file extensions of the content type from the built-in table,
+// the first extension is the preferred one.
+func ExtensionsByContentType(contentType string) []string {
+	return contentTypeExtensions[getMediaType(contentType)]
+}
+
+// AllowContentTypes set the content types allowed to be uploaded.
+// S3 can't match a list of values, so the policy use the tightest condition possible:
+// "eq" for one content type, otherwise "starts-with" the common prefix of all content types.
+// The content types must have the same type (ex: image/png and image/jpeg), otherwise it panics
+// because the common prefix would allow any content type.
+// The prefix allows other subtypes too, ex: image/png and image/jpeg still admit image/svg+xml,
+// so the content type must be checked again after the upload.
+// The list is kept in Policy.AllowedContentTypes and checked against the key extension on generation.
+func (base *BaseS3Policy) AllowContentTypes(contentTypes []string) *BaseS3Policy {
+	var allowedContentTypes []string
+	for _, contentType := range contentTypes {
+		contentType = getMediaType(contentType)
+		if contentType == "" || inStrings(allowedContentTypes, contentType) {
+			continue
+		}
+
+		allowedContentTypes = append(allowedContentTypes, contentType)
+	}
+
+	if len(allowedContentTypes) == 0 {
+		panic("allowed content types can't be empty")
+	}
+
+	if len(allowedContentTypes) == 1 {
+		base.SetContentTypePolicy(ConditionMatchingExactMatch, allowedContentTypes[0])
+	} else {
+		prefix := getCommonPrefix(allowedContentTypes)
+		if !strings.Contains(prefix, "/") {
+			panic(fmt.Sprintf("allowed content types %v must have the same type", allowedContentTypes))
+		}
+
+		base.SetContentTypePolicy(ConditionMatchingStartWith, prefix)
+	}
+
+	base.Policy.AllowedContentTypes = allowedContentTypes
+	return base
+}
+
+// SetContentTypeFromKey derive the Content-Type value from the key extension on generation,
+// only used when the key use "eq" condition.
+func (base *BaseS3Policy) SetContentTypeFromKey(derive bool) *BaseS3Policy {
+	base.ContentTypeFromKey = derive
+	return base
+}
+
+// check the key extension is consistent with the content type, and derive the content type if needed
+func (base *BaseS3Policy) checkContentType(policy *Policy) error {
+	if policy.Key.ConditionUsed != ConditionMatchingExactMatch {
+		return nil
+	}
+
+	extension := path.Ext(policy.Key.PolicyValue)
+	keyContentType := ContentTypeByExtension(extension)
+	if keyContentType == "" {
+		if base.ContentTypeFromKey {
+			return fmt.Errorf("can't derive content type from key [%s], unknown extension", policy.Key.PolicyValue)
+		}
+
+		// the Content-Type prefix allows other content types than the allowed list
+		if len(policy.AllowedContentTypes) > 0 && policy.ContentType.ConditionUsed != ConditionMatchingExactMatch {
+			return fmt.Errorf("key [%s] has unknown extension, can't check allowed content types %v", policy.Key.PolicyValue, policy.AllowedContentTypes)
+		}
+
+		return nil
+	}
+
+	if len(policy.AllowedContentTypes) > 0 && !inStrings(policy.AllowedContentTypes, keyContentType) {
+		return fmt.Errorf("key extension [%s] is not one of allowed content types %v", extension, policy.AllowedContentTypes)
+	}
+
+	switch policy.ContentType.ConditionUsed {
+	case ConditionMatchingExactMatch:
+		declared := getMediaType(policy.ContentType.PolicyValue)
+		if _, ok := contentTypeExtensions[declared]; ok && declared != keyContentType {
+			return fmt.Errorf("key extension [%s] doesn't match content type [%s]", extension, policy.ContentType.PolicyValue)
+		}
+	case ConditionMatchingStartWith:
+		if !strings.HasPrefix(keyContentType, policy.ContentType.PolicyValue) {
+			return fmt.Errorf("key extension [%s] doesn't match content type starts with [%s]", extension, policy.ContentType.PolicyValue)
+		}
+
+		if base.ContentTypeFromKey {
+			policy.ContentType.ConditionUsed = ConditionMatchingExactMatch
+			policy.ContentType.PolicyValue = keyContentType
+		}
+	default:
+		if base.ContentTypeFromKey {
+			policy.ContentType.ConditionUsed = ConditionMatchingExactMatch
+			policy.ContentType.PolicyValue = keyContentType
+		}
+	}
+
+	return nil
+}
+
+// return the lowercase media type without parameters, ex: "Text/Plain; charset=utf-8" => "text/plain"
+func getMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	return mediaType
+}
+
+func getCommonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+func inStrings(values []string, value string) bool {
+	for _, data := range values {
+		if data == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,107 @@
+package s3Presign
+
+import (
+	"path"
+	"testing"
+)
+
+func TestAllowContentTypes(t *testing.T) {
+	defaultData := getDefaultData()
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.AllowContentTypes([]string{"image/png", "image/jpeg", "image/webp", "image/png"})
+	if s3PolicyBase.Policy.ContentType.ConditionUsed != ConditionMatchingStartWith || s3PolicyBase.Policy.ContentType.PolicyValue != "image/" {
+		t.Errorf("content type condition should be starts-with [image/] not %s [%s]",
+			s3PolicyBase.Policy.ContentType.ConditionUsed, s3PolicyBase.Policy.ContentType.PolicyValue)
+	}
+
+	if len(s3PolicyBase.Policy.AllowedContentTypes) != 3 {
+		t.Errorf("allowed content types should be deduplicated, got %v", s3PolicyBase.Policy.AllowedContentTypes)
+	}
+
+	s3PolicyBase.AllowContentTypes([]string{"image/png"})
+	if s3PolicyBase.Policy.ContentType.ConditionUsed != ConditionMatchingExactMatch || s3PolicyBase.Policy.ContentType.PolicyValue != "image/png" {
+		t.Errorf("content type condition should be eq [image/png] not %s [%s]",
+			s3PolicyBase.Policy.ContentType.ConditionUsed, s3PolicyBase.Policy.ContentType.PolicyValue)
+	}
+
+	// the common prefix of different types would allow any content type
+	for _, contentTypes := range [][]string{{"image/png", "application/pdf"}, {"image/png", "images/png"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("content types %v should panic", contentTypes)
+				}
+			}()
+
+			NewS3Policy(defaultData.AwsConfig).AllowContentTypes(contentTypes)
+		}()
+	}
+}
+
+func TestContentTypeFromKey(t *testing.T) {
+	defaultData := getDefaultData()
+
+	testKeyValue := map[string]bool{
+		"user/user1/test.jpeg": true,
+		"user/user1/test.PNG":  true,
+		"user/user1/test.svg":  false, // not in allowed content types
+		"user/user1/test":      false, // unknown extension
+	}
+
+	for key, isValid := range testKeyValue {
+		s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+		s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, key)
+		s3PolicyBase.AllowContentTypes([]string{"image/png", "image/jpeg", "image/svg"})
+		s3PolicyBase.SetContentTypeFromKey(true)
+
+		_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+		if !isValid {
+			if err == nil {
+				t.Errorf("key [%s] should return error", key)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("key [%s] should be valid: %s", key, err.Error())
+			continue
+		}
+
+		for _, value := range formsData.FormData {
+			if value.FormName == "Content-Type" && value.FormValue != ContentTypeByExtension(path.Ext(key)) {
+				t.Errorf("key [%s] content type should be derived from extension, got [%s]", key, value.FormValue)
+			}
+		}
+	}
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetContentTypePolicy(ConditionMatchingExactMatch, "image/png")
+	if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("key [%s] with content type [image/png] should return error", defaultData.Key)
+	}
+}
+
+func TestAllowContentTypesUnknownExtension(t *testing.T) {
+	defaultData := getDefaultData()
+
+	// the key type can't be checked, and the Content-Type prefix allows other types
+	for _, key := range []string{"user/user1/report.xyz", "user/user1/report"} {
+		s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+		s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, key)
+		s3PolicyBase.AllowContentTypes([]string{"image/png", "image/jpeg"})
+		if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err == nil {
+			t.Errorf("key [%s] with unknown extension should return error", key)
+		}
+	}
+
+	// one allowed content type is enforced by the "eq" condition
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "user/user1/report")
+	s3PolicyBase.AllowContentTypes([]string{"image/png"})
+	if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err != nil {
+		t.Errorf("key with unknown extension and one allowed content type should be valid: %s", err.Error())
+	}
+}
@@ -0,0 +1,361 @@
+package s3Presign
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const ExplainFormatText = "text"
+const ExplainFormatMarkdown = "markdown"
+
+// diff change types, used as PolicyChange.Type
+const DiffAdded = "added"
+const DiffRemoved = "removed"
+const DiffChanged = "changed"
+
+// ExplainTimeFormat format of the expiration in the explanation
+const ExplainTimeFormat = "2006-01-02 15:04 MST"
+
+// PolicyDocument the unsigned policy document, the same as the base64 decoded policy
+type PolicyDocument struct {
+	Expiration time.Time
+	Conditions []Condition
+}
+
+// PolicyChange condition changed between two policies, Before and After are the explained conditions
+type PolicyChange struct {
+	Type   string
+	Field  string
+	Before string
+	After  string
+}
+
+type PolicyDiff struct {
+	Changes []PolicyChange
+}
+
+// Document get the policy document that will be signed by GeneratePolicyWithError
+func (base *BaseS3Policy) Document() (PolicyDocument, error) {
+	prepared, policy, err := base.preparePolicy()
+	if err != nil {
+		return PolicyDocument{}, err
+	}
+
+	return PolicyDocument{Expiration: prepared.ExpiredDate, Conditions: policy.getConditions()}, nil
+}
+
+// ParsePolicyDocument parse the policy document JSON, or the base64 encoded policy from the form
+func ParsePolicyDocument(data []byte) (PolicyDocument, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("{")) {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return PolicyDocument{}, fmt.Errorf("policy must be JSON or base64 encoded JSON: %w", err)
+		}
+
+		data = decoded
+	}
+
+	var document struct {
+		Expiration string        `json:"expiration"`
+		Conditions []interface{} `json:"conditions"`
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return PolicyDocument{}, fmt.Errorf("invalid policy document: %w", err)
+	}
+
+	expiration, err := time.Parse(time.RFC3339, document.Expiration)
+	if err != nil {
+		return PolicyDocument{}, fmt.Errorf("invalid policy expiration [%s]: %w", document.Expiration, err)
+	}
+
+	conditions, err := ParseConditions(document.Conditions)
+	if err != nil {
+		return PolicyDocument{}, err
+	}
+
+	return PolicyDocument{Expiration: expiration, Conditions: conditions}, nil
+}
+
+// Explain the policy conditions in plain English, format is ExplainFormatText or ExplainFormatMarkdown
+func Explain(base *BaseS3Policy, format string) (string, error) {
+	document, err := base.Document()
+	if err != nil {
+		return "", err
+	}
+
+	return document.Explain(format), nil
+}
+
+// Explain the policy document in plain English, format is ExplainFormatText or ExplainFormatMarkdown.
+// The conditions generated on signing (algorithm, credential and date) are not explained.
+func (document PolicyDocument) Explain(format string) string {
+	isMarkdown := format == ExplainFormatMarkdown
+	buffer := &bytes.Buffer{}
+
+	bucket := "any bucket"
+	for _, condition := range document.Conditions {
+		if condition.Field == "bucket" && condition.ConditionUsed == ConditionMatchingExactMatch {
+			bucket = "bucket " + formatExplainValue(condition.PolicyValue, isMarkdown)
+		}
+	}
+
+	expiration := document.Expiration.UTC().Format(ExplainTimeFormat)
+	if isMarkdown {
+		fmt.Fprintf(buffer, "**Upload to %s**, expires %s\n\n| Field | Condition |\n| --- | --- |\n", bucket, expiration)
+	} else {
+		fmt.Fprintf(buffer, "Upload to %s, expires %s\n", bucket, expiration)
+	}
+
+	for _, condition := range document.Conditions {
+		if isSigningCondition(condition.Field) {
+			continue
+		}
+
+		if isMarkdown {
+			fmt.Fprintf(buffer, "| %s | %s |\n", escapeMarkdownTable(condition.Field), escapeMarkdownTable(explainCondition(condition, true)))
+		} else {
+			fmt.Fprintf(buffer, "- %s %s\n", getExplainFieldName(condition.Field), explainCondition(condition, false))
+		}
+	}
+
+	return buffer.String()
+}
+
+// Diff get the conditions added, removed and changed from policy a to policy b,
+// the signing dates stamped by the clock use the same time so the relative expirations can be compared.
+func Diff(a, b *BaseS3Policy) (PolicyDiff, error) {
+	clock := a.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	now := clock.Now()
+	a, b = a.Clone(), b.Clone()
+	a.Clock = ClockFunc(func() time.Time { return now })
+	b.Clock = a.Clock
+
+	documentA, err := a.Document()
+	if err != nil {
+		return PolicyDiff{}, err
+	}
+
+	documentB, err := b.Document()
+	if err != nil {
+		return PolicyDiff{}, err
+	}
+
+	return DiffPolicyDocuments(documentA, documentB), nil
+}
+
+// DiffPolicyDocuments get the conditions added, removed and changed from document a to document b,
+// sorted by field. The conditions generated on signing are not compared,
+// the expiration is compared with the encoded precision (milliseconds).
+func DiffPolicyDocuments(a, b PolicyDocument) (diff PolicyDiff) {
+	if a.Expiration.UTC().Format(ExpirationFormat) != b.Expiration.UTC().Format(ExpirationFormat) {
+		diff.Changes = append(diff.Changes, PolicyChange{
+			Type:   DiffChanged,
+			Field:  "expiration",
+			Before: "expires " + a.Expiration.UTC().Format(ExplainTimeFormat),
+			After:  "expires " + b.Expiration.UTC().Format(ExplainTimeFormat),
+		})
+	}
+
+	conditionsA, conditionsB := getExplainedConditions(a.Conditions), getExplainedConditions(b.Conditions)
+	fields := make([]string, 0, len(conditionsA)+len(conditionsB))
+	for field := range conditionsA {
+		fields = append(fields, field)
+	}
+
+	for field := range conditionsB {
+		if _, ok := conditionsA[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+
+	sort.Strings(fields)
+	for _, field := range fields {
+		before, after := conditionsA[field], conditionsB[field]
+		switch {
+		case len(before) == 0:
+			for _, explained := range after {
+				diff.Changes = append(diff.Changes, PolicyChange{Type: DiffAdded, Field: field, After: explained})
+			}
+		case len(after) == 0:
+			for _, explained := range before {
+				diff.Changes = append(diff.Changes, PolicyChange{Type: DiffRemoved, Field: field, Before: explained})
+			}
+		case strings.Join(before, "\n") != strings.Join(after, "\n"):
+			diff.Changes = append(diff.Changes, PolicyChange{
+				Type:   DiffChanged,
+				Field:  field,
+				Before: strings.Join(before, "; "),
+				After:  strings.Join(after, "; "),
+			})
+		}
+	}
+
+	return diff
+}
+
+// IsEmpty true if the policies have the same conditions
+func (diff PolicyDiff) IsEmpty() bool {
+	return len(diff.Changes) == 0
+}
+
+// String the changes as text, "+" added, "-" removed and "~" changed condition
+func (diff PolicyDiff) String() string {
+	buffer := &bytes.Buffer{}
+	for _, change := range diff.Changes {
+		switch change.Type {
+		case DiffAdded:
+			fmt.Fprintf(buffer, "+ %s %s\n", change.Field, change.After)
+		case DiffRemoved:
+			fmt.Fprintf(buffer, "- %s %s\n", change.Field, change.Before)
+		default:
+			fmt.Fprintf(buffer, "~ %s %s -> %s\n", change.Field, change.Before, change.After)
+		}
+	}
+
+	return buffer.String()
+}
+
+// Markdown the changes as Markdown table
+func (diff PolicyDiff) Markdown() string {
+	buffer := bytes.NewBufferString("| Change | Field | Before | After |\n| --- | --- | --- | --- |\n")
+	for _, change := range diff.Changes {
+		fmt.Fprintf(buffer, "| %s | %s | %s | %s |\n", change.Type, escapeMarkdownTable(change.Field),
+			escapeMarkdownTable(change.Before), escapeMarkdownTable(change.After))
+	}
+
+	return buffer.String()
+}
+
+// get the explained conditions for each field, S3 field names are case-insensitive
+func getExplainedConditions(conditions []Condition) map[string][]string {
+	explained := make(map[string][]string, len(conditions))
+	for _, condition := range conditions {
+		if isSigningCondition(condition.Field) {
+			continue
+		}
+
+		field := strings.ToLower(condition.Field)
+		explained[field] = append(explained[field], explainCondition(condition, false))
+	}
+
+	for field := range explained {
+		sort.Strings(explained[field])
+	}
+
+	return explained
+}
+
+func explainCondition(condition Condition, isMarkdown bool) string {
+	switch condition.ConditionUsed {
+	case ConditionMatchingExactMatch:
+		return "must be " + formatExplainValue(condition.PolicyValue, isMarkdown)
+	case ConditionMatchingStartWith:
+		if condition.PolicyValue == "" {
+			return "can be any value"
+		}
+
+		return "must start with " + formatExplainValue(condition.PolicyValue, isMarkdown)
+	case ConditionSpecifyingRange:
+		return fmt.Sprintf("must be between %s and %s", formatExplainSize(condition.PolicyStartRange), formatExplainSize(condition.PolicyStopRange))
+	}
+
+	return fmt.Sprintf("has unknown condition [%s]", condition.ConditionUsed)
+}
+
+func getExplainFieldName(field string) string {
+	switch strings.ToLower(field) {
+	case "key":
+		return "Key"
+	case "bucket":
+		return "Bucket"
+	case "acl":
+		return "ACL"
+	case ConditionSpecifyingRange:
+		return "Size"
+	case "success_action_status":
+		return "Success status"
+	case "success_action_redirect":
+		return "Success redirect"
+	}
+
+	if name := strings.ToLower(field); strings.HasPrefix(name, "x-amz-meta-") {
+		return "Metadata " + strings.TrimPrefix(name, "x-amz-meta-")
+	}
+
+	return field
+}
+
+// value is quoted if it's empty, has space at the start or end, or has non-printable characters
+func formatExplainValue(value string, isMarkdown bool) string {
+	isQuoted := value == "" || strings.TrimSpace(value) != value
+	for _, char := range value {
+		if !unicode.IsPrint(char) {
+			isQuoted = true
+			break
+		}
+	}
+
+	if isQuoted {
+		value = strconv.Quote(value)
+	}
+
+	if !isMarkdown {
+		return value
+	}
+
+	if strings.Contains(value, "`") {
+		return "`` " + value + " ``"
+	}
+
+	return "`" + value + "`"
+}
+
+// size in bytes, with binary unit if the size is more than 1 KiB
+func formatExplainSize(size uint64) string {
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	unit := -1
+	value := float64(size)
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit < 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	if value == float64(uint64(value)) {
+		return fmt.Sprintf("%d %s", uint64(value), units[unit])
+	}
+
+	return fmt.Sprintf("%s %s (%d B)", strconv.FormatFloat(value, 'f', 2, 64), units[unit], size)
+}
+
+func escapeMarkdownTable(value string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(value)
+}
+
+// conditions generated on signing, they are different for every signing date
+func isSigningCondition(field string) bool {
+	switch strings.ToLower(field) {
+	case "x-amz-algorithm", "x-amz-credential", "x-amz-date":
+		return true
+	}
+
+	return false
+}
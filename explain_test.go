@@ -0,0 +1,200 @@
+package s3Presign
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func getExplainPolicy() *BaseS3Policy {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig, WithClock(clock), WithExpiry(12*time.Hour))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "users/42/")
+	s3PolicyBase.SetContentLengthPolicy(1, 10485760)
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, "private")
+	s3PolicyBase.SetXAmzMeta("tag", ConditionMatchingStartWith, "")
+	return s3PolicyBase
+}
+
+func TestExplain(t *testing.T) {
+	s3PolicyBase := getExplainPolicy()
+	s3PolicyBase.SetXAmzMeta("note", ConditionMatchingExactMatch, " a|b ")
+
+	text, err := Explain(s3PolicyBase, ExplainFormatText)
+	if err != nil {
+		t.Fatalf("failed to explain policy: %s", err.Error())
+	}
+
+	expected := `Upload to bucket sigv4examplebucket, expires 2015-12-29 12:00 UTC
+- ACL must be private
+- Bucket must be sigv4examplebucket
+- Size must be between 1 B and 10 MiB
+- Key must start with users/42/
+- Metadata note must be " a|b "
+- Metadata tag can be any value
+`
+	if text != expected {
+		t.Errorf("explanation should be:\n%s\nnot:\n%s", expected, text)
+	}
+
+	markdown, err := Explain(s3PolicyBase, ExplainFormatMarkdown)
+	if err != nil {
+		t.Fatalf("failed to explain policy: %s", err.Error())
+	}
+
+	expected = "**Upload to bucket `sigv4examplebucket`**, expires 2015-12-29 12:00 UTC\n\n" +
+		"| Field | Condition |\n| --- | --- |\n" +
+		"| acl | must be `private` |\n" +
+		"| bucket | must be `sigv4examplebucket` |\n" +
+		"| content-length-range | must be between 1 B and 10 MiB |\n" +
+		"| key | must start with `users/42/` |\n" +
+		"| x-amz-meta-note | must be `\" a\\|b \"` |\n" +
+		"| x-amz-meta-tag | can be any value |\n"
+	if markdown != expected {
+		t.Errorf("markdown should be:\n%s\nnot:\n%s", expected, markdown)
+	}
+
+	// explain doesn't change the policy
+	s3PolicyBase = getExplainPolicy()
+	before, _ := s3PolicyBase.State()
+	if _, err = s3PolicyBase.Document(); err != nil {
+		t.Fatalf("failed to get policy document: %s", err.Error())
+	}
+
+	if after, _ := s3PolicyBase.State(); !reflect.DeepEqual(before, after) || s3PolicyBase.Policy.Bucket.ConditionUsed != "" {
+		t.Errorf("explain should not change the policy: %+v", after)
+	}
+}
+
+func TestParsePolicyDocument(t *testing.T) {
+	s3PolicyBase := getExplainPolicy()
+	encodedPolicy, _, _, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	document, err := s3PolicyBase.Document()
+	if err != nil {
+		t.Fatalf("failed to get policy document: %s", err.Error())
+	}
+
+	// the same document from the base64 policy and the JSON policy
+	decodedPolicy, _ := base64.StdEncoding.DecodeString(encodedPolicy)
+	for _, data := range []string{encodedPolicy, string(decodedPolicy)} {
+		parsedDocument, err := ParsePolicyDocument([]byte(data))
+		if err != nil {
+			t.Fatalf("failed to parse policy document: %s", err.Error())
+		}
+
+		if !reflect.DeepEqual(parsedDocument.Conditions, document.Conditions) || !parsedDocument.Expiration.Equal(document.Expiration) {
+			t.Errorf("parsed document should be %+v not %+v", document, parsedDocument)
+		}
+	}
+
+	invalidDocuments := []string{
+		"not base64!",
+		`{"expiration":"tomorrow","conditions":[]}`,
+		`{"expiration":"2015-12-30T12:00:00.000Z","conditions":[["in","$key","a"]]}`,
+	}
+	for _, data := range invalidDocuments {
+		if _, err = ParsePolicyDocument([]byte(data)); err == nil {
+			t.Errorf("%s should return error", data)
+		}
+	}
+}
+
+func TestDiffSystemClock(t *testing.T) {
+	defaultData := getDefaultData()
+	newPolicy := func() *BaseS3Policy {
+		s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+		s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "users/42/")
+		return s3PolicyBase
+	}
+
+	a := newPolicy()
+	time.Sleep(2 * time.Millisecond)
+	b := newPolicy()
+	diff, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("failed to diff policies: %s", err.Error())
+	}
+
+	if !diff.IsEmpty() {
+		t.Errorf("the same policies should not have changes, got:\n%s", diff.String())
+	}
+
+	// the expiration is encoded in milliseconds
+	documentA := PolicyDocument{Expiration: defaultData.TimeExpired}
+	documentB := PolicyDocument{Expiration: defaultData.TimeExpired.Add(time.Microsecond)}
+	if diff = DiffPolicyDocuments(documentA, documentB); !diff.IsEmpty() {
+		t.Errorf("expiration less than a millisecond apart should not be changed, got:\n%s", diff.String())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := getExplainPolicy()
+	b := getExplainPolicy()
+	b.SetExpiresIn(time.Hour)
+	b.SetKeyPolicy(ConditionMatchingStartWith, "users/43/")
+	b.SetContentLengthPolicy(1, 1500000)
+	b.Policy.XAmzMeta = nil
+	b.SetContentTypePolicy(ConditionMatchingExactMatch, "image/png")
+
+	diff, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("failed to diff policies: %s", err.Error())
+	}
+
+	expected := []PolicyChange{
+		{Type: DiffChanged, Field: "expiration", Before: "expires 2015-12-29 12:00 UTC", After: "expires 2015-12-29 01:00 UTC"},
+		{Type: DiffChanged, Field: "content-length-range", Before: "must be between 1 B and 10 MiB", After: "must be between 1 B and 1.43 MiB (1500000 B)"},
+		{Type: DiffAdded, Field: "content-type", After: "must be image/png"},
+		{Type: DiffChanged, Field: "key", Before: "must start with users/42/", After: "must start with users/43/"},
+		{Type: DiffRemoved, Field: "x-amz-meta-tag", Before: "can be any value"},
+	}
+
+	if !reflect.DeepEqual(diff.Changes, expected) {
+		t.Errorf("changes should be %+v not %+v", expected, diff.Changes)
+	}
+
+	expectedText := `~ expiration expires 2015-12-29 12:00 UTC -> expires 2015-12-29 01:00 UTC
+~ content-length-range must be between 1 B and 10 MiB -> must be between 1 B and 1.43 MiB (1500000 B)
++ content-type must be image/png
+~ key must start with users/42/ -> must start with users/43/
+- x-amz-meta-tag can be any value
+`
+	if diff.String() != expectedText {
+		t.Errorf("diff should be:\n%s\nnot:\n%s", expectedText, diff.String())
+	}
+
+	// the signing date is not compared
+	c := getExplainPolicy()
+	c.Date = c.Date.Add(time.Hour)
+	c.SetExpirationDate(a.ExpiredDate)
+	if diff, _ = Diff(a, c); !diff.IsEmpty() {
+		t.Errorf("policies with different signing date should be the same: %s", diff.String())
+	}
+}
+
+func TestFormatExplainSize(t *testing.T) {
+	testCases := map[uint64]string{
+		0:           "0 B",
+		1023:        "1023 B",
+		1024:        "1 KiB",
+		1536:        "1.50 KiB (1536 B)",
+		10485760:    "10 MiB",
+		5368709120:  "5 GiB",
+		10000000000: "9.31 GiB (10000000000 B)",
+	}
+
+	for size, expected := range testCases {
+		if formatted := formatExplainSize(size); formatted != expected {
+			t.Errorf("%d should be formatted as %s not %s", size, expected, formatted)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package s3Presign
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// PostmanSchema schema of the collection generated by PostmanCollection
+const PostmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// CurlCommand get the curl command to upload the file with the form fields, the file is the last field.
+// The form fields use --form-string, curl -F would parse the values (@, <, ", ; and type=).
+func (forms Forms) CurlCommand(filePath string) string {
+	arguments := []string{"curl", shellQuote(forms.Url)}
+	for _, formData := range forms.getUniqueFormData() {
+		arguments = append(arguments, "--form-string "+shellQuote(formData.FormName+"="+formData.FormValue))
+	}
+
+	// curl parse ; and , in the file name, double quote it
+	if strings.ContainsAny(filePath, `;,"\`) {
+		filePath = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(filePath) + `"`
+	}
+
+	arguments = append(arguments, "-F "+shellQuote(FormFileField+"=@"+filePath))
+	return strings.Join(arguments, " \\\n  ")
+}
+
+// HTTPieCommand get the HTTPie command to upload the file with the form fields, the file is the last field.
+func (forms Forms) HTTPieCommand(filePath string) string {
+	arguments := []string{"http --form POST", shellQuote(forms.Url)}
+	for _, formData := range forms.getUniqueFormData() {
+		// value starting with = or @ would be parsed as == (query) or =@ (file content) separator
+		value := formData.FormValue
+		if strings.HasPrefix(value, "=") || strings.HasPrefix(value, "@") {
+			value = `\` + value
+		}
+
+		arguments = append(arguments, shellQuote(formData.FormName+"="+value))
+	}
+
+	arguments = append(arguments, shellQuote(FormFileField+"@"+filePath))
+	return strings.Join(arguments, " \\\n  ")
+}
+
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item []postmanItem `json:"item"`
+}
+
+type postmanItem struct {
+	Name    string `json:"name"`
+	Request struct {
+		Method string `json:"method"`
+		Url    string `json:"url"`
+		Body   struct {
+			Mode     string            `json:"mode"`
+			FormData []postmanFormData `json:"formdata"`
+		} `json:"body"`
+	} `json:"request"`
+}
+
+type postmanFormData struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Type  string `json:"type"`
+	Src   string `json:"src,omitempty"`
+}
+
+// PostmanCollection get Postman collection (v2.1) with one upload request, the file is the last field.
+func (forms Forms) PostmanCollection(name, filePath string) ([]byte, error) {
+	item := postmanItem{Name: "Upload to Amazon S3"}
+	item.Request.Method = "POST"
+	item.Request.Url = forms.Url
+	item.Request.Body.Mode = "formdata"
+	for _, formData := range forms.getUniqueFormData() {
+		item.Request.Body.FormData = append(item.Request.Body.FormData, postmanFormData{
+			Key:   formData.FormName,
+			Value: formData.FormValue,
+			Type:  "text",
+		})
+	}
+
+	item.Request.Body.FormData = append(item.Request.Body.FormData, postmanFormData{Key: FormFileField, Type: "file", Src: filePath})
+
+	var collection postmanCollection
+	collection.Info.Name = name
+	collection.Info.Schema = PostmanSchema
+	collection.Item = []postmanItem{item}
+	return json.MarshalIndent(collection, "", "  ")
+}
+
+// quote the value for POSIX shell, the value is not quoted if it's safe
+func shellQuote(value string) string {
+	if value == "" {
+		return "''"
+	}
+
+	isSafe := true
+	for _, char := range value {
+		if !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || strings.ContainsRune("@%+=:,./_-", char)) {
+			isSafe = false
+			break
+		}
+	}
+
+	if isSafe {
+		return value
+	}
+
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,189 @@
+package s3Presign
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func getExportForms() Forms {
+	return Forms{
+		Url: "https://sigv4examplebucket.s3.amazonaws.com/",
+		FormData: []FormData{
+			{FormName: "key", FormValue: "user/it's here.jpeg"},
+			{FormName: "Content-Disposition", FormValue: `attachment; filename="test.jpeg"`},
+			{FormName: "x-amz-meta-tag", FormValue: "@home"},
+			{FormName: "x-amz-meta-title", FormValue: "=$(whoami) `id`"},
+			{FormName: "x-amz-meta-quote", FormValue: `"quoted" value;type=text/plain`},
+			{FormName: "policy", FormValue: "eyJleHBpcmF0aW9uIjoiMjAxNS0xMi0zMFQxMjowMDowMC4wMDBaIn0="},
+		},
+	}
+}
+
+// get the arguments parsed by shell, using printf instead of the command
+func getShellArguments(t *testing.T, command, program string) []string {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	output, err := exec.Command("sh", "-c", `printf '%s\n'`+strings.TrimPrefix(command, program)).Output()
+	if err != nil {
+		t.Fatalf("invalid shell command %s: %s", command, err.Error())
+	}
+
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+}
+
+func TestCurlCommand(t *testing.T) {
+	forms := getExportForms()
+	command := forms.CurlCommand("/tmp/my file.jpeg")
+
+	expected := []string{
+		"https://sigv4examplebucket.s3.amazonaws.com/",
+		"--form-string", "key=user/it's here.jpeg",
+		"--form-string", `Content-Disposition=attachment; filename="test.jpeg"`,
+		"--form-string", "x-amz-meta-tag=@home",
+		"--form-string", "x-amz-meta-title==$(whoami) `id`",
+		"--form-string", `x-amz-meta-quote="quoted" value;type=text/plain`,
+		"--form-string", "policy=eyJleHBpcmF0aW9uIjoiMjAxNS0xMi0zMFQxMjowMDowMC4wMDBaIn0=",
+		"-F", "file=@/tmp/my file.jpeg",
+	}
+
+	arguments := getShellArguments(t, command, "curl")
+	if strings.Join(arguments, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("curl arguments should be %q not %q", expected, arguments)
+	}
+
+	if !strings.HasSuffix(forms.CurlCommand("a;b.jpeg"), `-F 'file=@"a;b.jpeg"'`) {
+		t.Errorf("file name with ; should be double quoted: %s", forms.CurlCommand("a;b.jpeg"))
+	}
+}
+
+func TestCurlCommandUpload(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not installed")
+	}
+
+	filePath := filepath.Join(t.TempDir(), "test.jpeg")
+	_ = os.WriteFile(filePath, []byte("file content"), 0o600)
+
+	var names []string
+	received := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		reader, err := request.MultipartReader()
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		for part, err := reader.NextPart(); err == nil; part, err = reader.NextPart() {
+			content, _ := io.ReadAll(part)
+			names = append(names, part.FormName())
+			received[part.FormName()] = string(content)
+		}
+
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	forms := getExportForms()
+	forms.Url = server.URL
+	if output, err := exec.Command("sh", "-c", forms.CurlCommand(filePath)+" --silent --fail").CombinedOutput(); err != nil {
+		t.Fatalf("curl failed: %s %s", err.Error(), string(output))
+	}
+
+	for _, formData := range forms.FormData {
+		if received[formData.FormName] != formData.FormValue {
+			t.Errorf("field [%s] should be [%s] not [%s]", formData.FormName, formData.FormValue, received[formData.FormName])
+		}
+	}
+
+	if len(names) == 0 || names[len(names)-1] != FormFileField || received[FormFileField] != "file content" {
+		t.Errorf("file should be the last field: %v", names)
+	}
+}
+
+func TestHTTPieCommand(t *testing.T) {
+	forms := getExportForms()
+	command := forms.HTTPieCommand("/tmp/my file.jpeg")
+	if !strings.HasPrefix(command, "http --form POST ") {
+		t.Fatalf("command should be HTTPie form request: %s", command)
+	}
+
+	expected := []string{
+		"--form", "POST",
+		"https://sigv4examplebucket.s3.amazonaws.com/",
+		"key=user/it's here.jpeg",
+		`Content-Disposition=attachment; filename="test.jpeg"`,
+		`x-amz-meta-tag=\@home`,
+		"x-amz-meta-title=\\=$(whoami) `id`",
+		`x-amz-meta-quote="quoted" value;type=text/plain`,
+		"policy=eyJleHBpcmF0aW9uIjoiMjAxNS0xMi0zMFQxMjowMDowMC4wMDBaIn0=",
+		"file@/tmp/my file.jpeg",
+	}
+
+	arguments := getShellArguments(t, command, "http")
+	if strings.Join(arguments, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("HTTPie arguments should be %q not %q", expected, arguments)
+	}
+}
+
+func TestPostmanCollection(t *testing.T) {
+	forms := getExportForms()
+	output, err := forms.PostmanCollection("S3 upload", "/tmp/test.jpeg")
+	if err != nil {
+		t.Fatalf("failed to generate collection: %s", err.Error())
+	}
+
+	var collection postmanCollection
+	if err = json.Unmarshal(output, &collection); err != nil {
+		t.Fatalf("invalid collection: %s", err.Error())
+	}
+
+	if collection.Info.Name != "S3 upload" || collection.Info.Schema != PostmanSchema || len(collection.Item) != 1 {
+		t.Fatalf("invalid collection info: %s", string(output))
+	}
+
+	request := collection.Item[0].Request
+	if request.Method != "POST" || request.Url != forms.Url || request.Body.Mode != "formdata" {
+		t.Errorf("invalid request: %+v", request)
+	}
+
+	formData := request.Body.FormData
+	if len(formData) != len(forms.FormData)+1 {
+		t.Fatalf("request should have %d fields not %d", len(forms.FormData)+1, len(formData))
+	}
+
+	for idx, field := range forms.FormData {
+		if formData[idx] != (postmanFormData{Key: field.FormName, Value: field.FormValue, Type: "text"}) {
+			t.Errorf("field %d should be %+v not %+v", idx, field, formData[idx])
+		}
+	}
+
+	if formData[len(formData)-1] != (postmanFormData{Key: FormFileField, Type: "file", Src: "/tmp/test.jpeg"}) {
+		t.Errorf("file should be the last field: %+v", formData[len(formData)-1])
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	testCases := map[string]string{
+		"":                 "''",
+		"user/test.jpeg":   "user/test.jpeg",
+		"a b":              "'a b'",
+		"it's":             `'it'\''s'`,
+		"$HOME":            "'$HOME'",
+		"key=value+base64": "key=value+base64",
+	}
+
+	for value, expected := range testCases {
+		if quoted := shellQuote(value); quoted != expected {
+			t.Errorf("%s should be quoted as %s not %s", value, expected, quoted)
+		}
+	}
+}
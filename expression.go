@@ -0,0 +1,466 @@
+package s3Presign
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ExpressionSizeField alias of content-length-range in the policy expression
+const ExpressionSizeField = "size"
+
+// size units of the policy expression, ex: 1B..25MiB
+var expressionSizeUnits = []struct {
+	name string
+	size uint64
+}{
+	{name: "TiB", size: 1 << 40},
+	{name: "TB", size: 1000000000000},
+	{name: "GiB", size: 1 << 30},
+	{name: "GB", size: 1000000000},
+	{name: "MiB", size: 1 << 20},
+	{name: "MB", size: 1000000},
+	{name: "KiB", size: 1 << 10},
+	{name: "KB", size: 1000},
+	{name: "B", size: 1},
+}
+
+// ExpressionError returned when the policy expression can't be parsed or applied, Column start from 1
+type ExpressionError struct {
+	Column  int
+	Message string
+}
+
+func (expressionError ExpressionError) Error() string {
+	return fmt.Sprintf("invalid policy expression at column %d: %s", expressionError.Column, expressionError.Message)
+}
+
+type expressionTokenType int
+
+const (
+	expressionTokenEnd expressionTokenType = iota
+	expressionTokenName
+	expressionTokenString
+	expressionTokenSize
+	expressionTokenSymbol
+)
+
+type expressionToken struct {
+	tokenType expressionTokenType
+	value     string
+	column    int
+}
+
+func (token expressionToken) String() string {
+	if token.tokenType == expressionTokenEnd {
+		return "end of expression"
+	}
+
+	return strconv.Quote(token.value)
+}
+
+type expressionClause struct {
+	field    expressionToken
+	operator string
+	values   []string
+	min, max uint64
+}
+
+// ParseExpression parse the policy expression to policy conditions, see SetExpression for the syntax
+func ParseExpression(expression string) (*Policy, error) {
+	base := NewS3Policy(AwsConfig{})
+	if err := base.SetExpression(expression); err != nil {
+		return nil, err
+	}
+
+	return base.Policy, nil
+}
+
+// SetExpression set the policy conditions from the expression, the policy is not changed if the expression is invalid.
+// The clauses are separated by "and", ex:
+//
+//	key ^= "uploads/user1/" and size in 1B..25MiB and content-type in ("image/png", "image/jpeg") and acl == "private"
+//
+// The clauses are:
+//   - field == "value" for "eq" condition
+//   - field ^= "value" for "starts-with" condition, ^= "" to allow any value
+//   - size in min..max for content-length-range, the size units are B, KB, MB, GB, TB, KiB, MiB, GiB and TiB
+//   - content-type in ("a", "b") to allow the content types, like AllowContentTypes
+//
+// Field names are case-insensitive, the values use Go string syntax. A field can be set only once in the expression.
+// Field names with other characters than letters, digits, "-", "_" and "$" are quoted, ex: "x-amz-meta-a.b" == "value".
+// Fields that are not policy fields (x-amz-meta-*, x-amz-*, or fields registered with RegisterPolicyField) are added with AddCondition.
+func (base *BaseS3Policy) SetExpression(expression string) error {
+	clauses, err := parseExpression(expression)
+	if err != nil {
+		return err
+	}
+
+	clone := base.Clone()
+	if clone.Policy == nil {
+		clone.Policy = &Policy{}
+	}
+
+	fieldNames := make(map[string]bool, len(clauses))
+	for _, clause := range clauses {
+		lowerName := strings.ToLower(clause.fieldName())
+		if fieldNames[lowerName] {
+			return ExpressionError{Column: clause.field.column, Message: fmt.Sprintf("field [%s] is set more than once", clause.fieldName())}
+		}
+
+		fieldNames[lowerName] = true
+		if err = clone.setExpressionClause(clause); err != nil {
+			return err
+		}
+	}
+
+	base.Policy = clone.Policy
+	return nil
+}
+
+// Expression format the policy conditions as policy expression, the conditions generated on signing are not formatted
+func (policy *Policy) Expression() string {
+	var clauses []string
+	for _, field := range policy.getFields() {
+		condition := *field.condition
+		if condition.ConditionUsed == "" || isSigningCondition(field.name) || field.name == "x-amz-security-token" {
+			continue
+		}
+
+		if field.name == "Content-Type" && len(policy.AllowedContentTypes) > 0 {
+			values := make([]string, 0, len(policy.AllowedContentTypes))
+			for _, contentType := range policy.AllowedContentTypes {
+				values = append(values, strconv.Quote(contentType))
+			}
+
+			clauses = append(clauses, fmt.Sprintf("%s in (%s)", field.name, strings.Join(values, ", ")))
+			continue
+		}
+
+		clauses = append(clauses, formatExpressionClause(newCondition(field.name, condition)))
+	}
+
+	for _, policyConditions := range []map[string]PolicyConditions{policy.XAmzMeta, policy.XAmz} {
+		names := make([]string, 0, len(policyConditions))
+		for name := range policyConditions {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			clauses = append(clauses, formatExpressionClause(newCondition(name, policyConditions[name])))
+		}
+	}
+
+	for _, condition := range policy.Conditions {
+		clauses = append(clauses, formatExpressionClause(condition))
+	}
+
+	return strings.Join(clauses, " and ")
+}
+
+func formatExpressionClause(condition Condition) string {
+	switch condition.ConditionUsed {
+	case ConditionSpecifyingRange:
+		return fmt.Sprintf("%s in %s..%s", ExpressionSizeField, formatExpressionSize(condition.PolicyStartRange), formatExpressionSize(condition.PolicyStopRange))
+	case ConditionMatchingStartWith:
+		return fmt.Sprintf("%s ^= %s", formatExpressionName(condition.Field), strconv.Quote(condition.PolicyValue))
+	default:
+		return fmt.Sprintf("%s == %s", formatExpressionName(condition.Field), strconv.Quote(condition.PolicyValue))
+	}
+}
+
+// quote the field name when the tokenizer can't read it as name
+func formatExpressionName(name string) string {
+	if name == "" || strings.EqualFold(name, "and") || !isExpressionNameRune([]rune(name)[0]) {
+		return strconv.Quote(name)
+	}
+
+	for _, value := range name {
+		if !isExpressionNameRune(value) && !unicode.IsDigit(value) {
+			return strconv.Quote(name)
+		}
+	}
+
+	return name
+}
+
+// format the size with the biggest unit without fraction
+func formatExpressionSize(size uint64) string {
+	for _, unit := range expressionSizeUnits {
+		if size >= unit.size && size%unit.size == 0 {
+			return fmt.Sprintf("%d%s", size/unit.size, unit.name)
+		}
+	}
+
+	return "0B"
+}
+
+// set the clause condition, the setters panic is returned as error at the field column
+func (base *BaseS3Policy) setExpressionClause(clause expressionClause) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = ExpressionError{Column: clause.field.column, Message: fmt.Sprint(recovered)}
+		}
+	}()
+
+	fieldName := clause.fieldName()
+	lowerName := strings.ToLower(fieldName)
+	if isSigningCondition(lowerName) || lowerName == "x-amz-security-token" {
+		return ExpressionError{Column: clause.field.column, Message: fmt.Sprintf("field [%s] is set on signing", fieldName)}
+	}
+
+	switch clause.operator {
+	case ConditionSpecifyingRange:
+		if lowerName != ConditionSpecifyingRange {
+			return ExpressionError{Column: clause.field.column, Message: fmt.Sprintf("size range can't be used for field [%s]", fieldName)}
+		}
+
+		base.SetContentLengthPolicy(clause.min, clause.max)
+		return nil
+	case "in":
+		if lowerName != "content-type" {
+			return ExpressionError{Column: clause.field.column, Message: fmt.Sprintf("list can't be used for field [%s], only for content-type", fieldName)}
+		}
+
+		base.AllowContentTypes(clause.values)
+		return nil
+	}
+
+	state := ConditionState{Field: fieldName, Condition: clause.operator, Value: clause.values[0]}
+	for _, field := range base.Policy.getFields() {
+		if strings.EqualFold(field.name, fieldName) {
+			state.Field = field.name
+			base.setConditionState(state)
+			return nil
+		}
+	}
+
+	if strings.HasPrefix(lowerName, XAmzKey) {
+		state.Field = lowerName
+		base.setConditionState(state)
+		return nil
+	}
+
+	if _, ok := GetPolicyField(fieldName); !ok {
+		return ExpressionError{Column: clause.field.column, Message: fmt.Sprintf("unknown field [%s]", fieldName)}
+	}
+
+	base.AddCondition(fieldName, clause.operator, clause.values[0])
+	return nil
+}
+
+// policy field name of the clause, size is content-length-range
+func (clause expressionClause) fieldName() string {
+	fieldName := strings.TrimPrefix(clause.field.value, "$")
+	if strings.EqualFold(fieldName, ExpressionSizeField) {
+		return ConditionSpecifyingRange
+	}
+
+	return fieldName
+}
+
+// parse the expression clauses, return ExpressionError at the invalid token
+func parseExpression(expression string) ([]expressionClause, error) {
+	tokens, err := getExpressionTokens(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	position := 0
+	next := func() expressionToken {
+		token := tokens[position]
+		if token.tokenType != expressionTokenEnd {
+			position++
+		}
+
+		return token
+	}
+
+	unexpected := func(token expressionToken, expected string) error {
+		return ExpressionError{Column: token.column, Message: fmt.Sprintf("expected %s, found %s", expected, token)}
+	}
+
+	var clauses []expressionClause
+	for {
+		clause := expressionClause{field: next()}
+		isName := clause.field.tokenType == expressionTokenName && !strings.EqualFold(clause.field.value, "and")
+		if !isName && clause.field.tokenType != expressionTokenString {
+			return nil, unexpected(clause.field, "field name")
+		}
+
+		operator := next()
+		switch {
+		case operator.tokenType == expressionTokenSymbol && (operator.value == "==" || operator.value == "^="):
+			clause.operator = ConditionMatchingExactMatch
+			if operator.value == "^=" {
+				clause.operator = ConditionMatchingStartWith
+			}
+
+			value := next()
+			if value.tokenType != expressionTokenString {
+				return nil, unexpected(value, "string value")
+			}
+
+			clause.values = []string{value.value}
+		case operator.tokenType == expressionTokenName && strings.EqualFold(operator.value, "in"):
+			value := next()
+			switch {
+			case value.tokenType == expressionTokenSize:
+				clause.operator = ConditionSpecifyingRange
+				if clause.min, err = parseExpressionSize(value); err != nil {
+					return nil, err
+				}
+
+				if separator := next(); separator.tokenType != expressionTokenSymbol || separator.value != ".." {
+					return nil, unexpected(separator, `".."`)
+				}
+
+				maxValue := next()
+				if maxValue.tokenType != expressionTokenSize {
+					return nil, unexpected(maxValue, "size")
+				}
+
+				if clause.max, err = parseExpressionSize(maxValue); err != nil {
+					return nil, err
+				}
+
+				if clause.min > clause.max {
+					return nil, ExpressionError{Column: value.column, Message: fmt.Sprintf("size range %d-%d is empty", clause.min, clause.max)}
+				}
+			case value.tokenType == expressionTokenSymbol && value.value == "(":
+				clause.operator = "in"
+				for {
+					item := next()
+					if item.tokenType != expressionTokenString {
+						return nil, unexpected(item, "string value")
+					}
+
+					clause.values = append(clause.values, item.value)
+					separator := next()
+					if separator.tokenType == expressionTokenSymbol && separator.value == ")" {
+						break
+					}
+
+					if separator.tokenType != expressionTokenSymbol || separator.value != "," {
+						return nil, unexpected(separator, `"," or ")"`)
+					}
+				}
+			default:
+				return nil, unexpected(value, "size range or list")
+			}
+		default:
+			return nil, unexpected(operator, `"==", "^=" or "in"`)
+		}
+
+		clauses = append(clauses, clause)
+
+		separator := next()
+		if separator.tokenType == expressionTokenEnd {
+			return clauses, nil
+		}
+
+		if separator.tokenType != expressionTokenName || !strings.EqualFold(separator.value, "and") {
+			return nil, unexpected(separator, `"and"`)
+		}
+	}
+}
+
+func parseExpressionSize(token expressionToken) (uint64, error) {
+	digits := strings.TrimRightFunc(token.value, unicode.IsLetter)
+	unitName := token.value[len(digits):]
+
+	size, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return 0, ExpressionError{Column: token.column, Message: fmt.Sprintf("invalid size %s", token)}
+	}
+
+	if unitName == "" {
+		return size, nil
+	}
+
+	for _, unit := range expressionSizeUnits {
+		if unit.name != unitName {
+			continue
+		}
+
+		if size > ^uint64(0)/unit.size {
+			return 0, ExpressionError{Column: token.column, Message: fmt.Sprintf("size %s is too big", token)}
+		}
+
+		return size * unit.size, nil
+	}
+
+	return 0, ExpressionError{Column: token.column, Message: fmt.Sprintf("unknown size unit %s, use B, KB, MB, GB, TB, KiB, MiB, GiB or TiB", strconv.Quote(unitName))}
+}
+
+// split the expression to tokens, the last token is always expressionTokenEnd
+func getExpressionTokens(expression string) ([]expressionToken, error) {
+	var tokens []expressionToken
+	runes := []rune(expression)
+	for idx := 0; idx < len(runes); {
+		current := runes[idx]
+		column := idx + 1
+		switch {
+		case unicode.IsSpace(current):
+			idx++
+		case current == '"':
+			end := idx + 1
+			for ; end < len(runes) && runes[end] != '"'; end++ {
+				if runes[end] == '\\' {
+					end++
+				}
+			}
+
+			if end >= len(runes) {
+				return nil, ExpressionError{Column: column, Message: "string is not closed"}
+			}
+
+			value, err := strconv.Unquote(string(runes[idx : end+1]))
+			if err != nil {
+				return nil, ExpressionError{Column: column, Message: fmt.Sprintf("invalid string %s", string(runes[idx:end+1]))}
+			}
+
+			tokens = append(tokens, expressionToken{tokenType: expressionTokenString, value: value, column: column})
+			idx = end + 1
+		case unicode.IsDigit(current):
+			end := idx
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || unicode.IsLetter(runes[end])) {
+				end++
+			}
+
+			tokens = append(tokens, expressionToken{tokenType: expressionTokenSize, value: string(runes[idx:end]), column: column})
+			idx = end
+		case isExpressionNameRune(current):
+			end := idx
+			for end < len(runes) && (isExpressionNameRune(runes[end]) || unicode.IsDigit(runes[end])) {
+				end++
+			}
+
+			tokens = append(tokens, expressionToken{tokenType: expressionTokenName, value: string(runes[idx:end]), column: column})
+			idx = end
+		default:
+			symbol := string(current)
+			if idx+1 < len(runes) {
+				if twoRunes := string(runes[idx : idx+2]); twoRunes == "==" || twoRunes == "^=" || twoRunes == ".." {
+					symbol = twoRunes
+				}
+			}
+
+			if !strings.Contains("(),", symbol) && len(symbol) == 1 {
+				return nil, ExpressionError{Column: column, Message: fmt.Sprintf("unexpected character %s", strconv.Quote(symbol))}
+			}
+
+			tokens = append(tokens, expressionToken{tokenType: expressionTokenSymbol, value: symbol, column: column})
+			idx += len([]rune(symbol))
+		}
+	}
+
+	return append(tokens, expressionToken{tokenType: expressionTokenEnd, column: len(runes) + 1}), nil
+}
+
+func isExpressionNameRune(value rune) bool {
+	return unicode.IsLetter(value) || value == '-' || value == '_' || value == '$'
+}
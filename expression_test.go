@@ -0,0 +1,100 @@
+package s3Presign
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	policy, err := ParseExpression(`key ^= "uploads/user1/" and size in 1B..25MiB and ` +
+		`content-type in ("image/png","image/jpeg") and acl == "private" and x-amz-meta-tag ^= "" and $success_action_status == "201"`)
+	if err != nil {
+		t.Fatalf("failed to parse expression: %s", err.Error())
+	}
+
+	s3PolicyBase := NewS3Policy(AwsConfig{})
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "uploads/user1/")
+	s3PolicyBase.SetContentLengthPolicy(1, 26214400)
+	s3PolicyBase.AllowContentTypes([]string{"image/png", "image/jpeg"})
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, "private")
+	s3PolicyBase.SetXAmzMeta("tag", ConditionMatchingStartWith, "")
+	s3PolicyBase.SetSuccessActionStatusPolicy(ConditionMatchingExactMatch, "201")
+
+	if !reflect.DeepEqual(policy, s3PolicyBase.Policy) {
+		t.Errorf("policy should be %+v not %+v", s3PolicyBase.Policy, policy)
+	}
+
+	expected := `acl == "private" and size in 1B..25MiB and Content-Type in ("image/png", "image/jpeg") and ` +
+		`key ^= "uploads/user1/" and success_action_status == "201" and x-amz-meta-tag ^= ""`
+	if expression := policy.Expression(); expression != expected {
+		t.Errorf("expression should be:\n%s\nnot:\n%s", expected, expression)
+	}
+
+	// formatted expression is parsed to the same policy
+	formattedPolicy, err := ParseExpression(policy.Expression())
+	if err != nil || !reflect.DeepEqual(formattedPolicy, policy) {
+		t.Errorf("formatted expression should be parsed to the same policy: %v %+v", err, formattedPolicy)
+	}
+}
+
+func TestSetExpressionError(t *testing.T) {
+	testCases := map[string]ExpressionError{
+		``:                                    {Column: 1, Message: "expected field name, found end of expression"},
+		`key = "a"`:                           {Column: 5, Message: `unexpected character "="`},
+		`key == a`:                            {Column: 8, Message: `expected string value, found "a"`},
+		`key == "a`:                           {Column: 8, Message: "string is not closed"},
+		`key == "a" acl == "private"`:         {Column: 12, Message: `expected "and", found "acl"`},
+		`key == "a" and`:                      {Column: 15, Message: "expected field name, found end of expression"},
+		`size in 1B..25XB`:                    {Column: 13, Message: `unknown size unit "XB", use B, KB, MB, GB, TB, KiB, MiB, GiB or TiB`},
+		`size in 2MB..1MB`:                    {Column: 9, Message: "size range 2000000-1000000 is empty"},
+		`size in 1B 2B`:                       {Column: 12, Message: `expected "..", found "2B"`},
+		`key in 1B..2B`:                       {Column: 1, Message: "size range can't be used for field [key]"},
+		`acl in ("private")`:                  {Column: 1, Message: "list can't be used for field [acl], only for content-type"},
+		`content-type in ("image/png" "a")`:   {Column: 30, Message: `expected "," or ")", found "a"`},
+		`key == "a" and unknown == "b"`:       {Column: 16, Message: "unknown field [unknown]"},
+		`tagging ^= "a"`:                      {Column: 1, Message: "condition matching type can't be used"},
+		`x-amz-date == "20151229T000000Z"`:    {Column: 1, Message: "field [x-amz-date] is set on signing"},
+		`key == "é" and x-amz-date == "a"`:    {Column: 16, Message: "field [x-amz-date] is set on signing"},
+		`size in 99999999999999999999TiB..1B`: {Column: 9, Message: `invalid size "99999999999999999999TiB"`},
+		`key == "a" and key ^= "b"`:           {Column: 16, Message: "field [key] is set more than once"},
+		`size in 1B..2B and $SIZE in 1B..3B`:  {Column: 20, Message: "field [content-length-range] is set more than once"},
+		`"x-amz-meta-a.b" == "a" and x-amz-meta-A.b == "b"`:   {Column: 41, Message: `unexpected character "."`},
+		`"x-amz-meta-a.b" == "a" and "X-Amz-Meta-A.b" == "b"`: {Column: 29, Message: "field [X-Amz-Meta-A.b] is set more than once"},
+	}
+
+	for expression, expected := range testCases {
+		s3PolicyBase := NewS3Policy(AwsConfig{})
+		s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "original")
+
+		err := s3PolicyBase.SetExpression(expression)
+
+		var expressionError ExpressionError
+		if !errors.As(err, &expressionError) || expressionError != expected {
+			t.Errorf("%s should return %+v not %v", expression, expected, err)
+		}
+
+		if s3PolicyBase.Policy.Key.PolicyValue != "original" {
+			t.Errorf("%s should not change the policy", expression)
+		}
+	}
+}
+
+func TestExpressionQuotedName(t *testing.T) {
+	s3PolicyBase := NewS3Policy(AwsConfig{})
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "uploads/report.pdf")
+	s3PolicyBase.SetXAmzMeta("a.b", ConditionMatchingExactMatch, "x")
+	s3PolicyBase.SetXAmzMeta("and", ConditionMatchingStartWith, "")
+	s3PolicyBase.SetXAmzMeta("tag", ConditionMatchingExactMatch, "y")
+
+	expected := `key == "uploads/report.pdf" and "x-amz-meta-a.b" == "x" and x-amz-meta-and ^= "" and x-amz-meta-tag == "y"`
+	expression := s3PolicyBase.Policy.Expression()
+	if expression != expected {
+		t.Errorf("expression should be:\n%s\nnot:\n%s", expected, expression)
+	}
+
+	policy, err := ParseExpression(expression)
+	if err != nil || !reflect.DeepEqual(policy, s3PolicyBase.Policy) {
+		t.Errorf("formatted expression should be parsed to the same policy: %v %+v", err, policy)
+	}
+}
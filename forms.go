@@ -0,0 +1,140 @@
+package s3Presign
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"time"
+)
+
+// FormFileField name of the file form field, S3 ignore the fields after the file
+const FormFileField = "file"
+
+// MarshalJSON encode the forms as {"url": "...", "fields": {...}}, the fields are in the same order as FormData.
+// The expiration is added only if it's set, and the upload constraints only if the forms have the policy conditions.
+func (forms Forms) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBuffer(make([]byte, 0, 64+len(forms.FormData)*64))
+	buffer.WriteString(`{"url":`)
+	writeJSONString(buffer, forms.Url)
+	buffer.WriteString(`,"fields":{`)
+	for idx, formData := range forms.getUniqueFormData() {
+		if idx > 0 {
+			buffer.WriteByte(',')
+		}
+
+		writeJSONString(buffer, formData.FormName)
+		buffer.WriteByte(':')
+		writeJSONString(buffer, formData.FormValue)
+	}
+
+	buffer.WriteByte('}')
+	if !forms.Expiration.IsZero() {
+		buffer.WriteString(`,"expiration":`)
+		writeJSONString(buffer, forms.Expiration.UTC().Format(time.RFC3339))
+	}
+
+	if len(forms.Conditions) > 0 {
+		constraints, err := json.Marshal(forms.Constraints())
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.WriteString(`,"constraints":`)
+		buffer.Write(constraints)
+	}
+
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalJSON decode the forms from {"url": "...", "fields": {...}}, keeping the fields order.
+// The policy conditions are not decoded.
+func (forms *Forms) UnmarshalJSON(data []byte) error {
+	var document struct {
+		Url        string          `json:"url"`
+		Fields     json.RawMessage `json:"fields"`
+		Expiration time.Time       `json:"expiration"`
+	}
+
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	var formsData []FormData
+	if len(document.Fields) > 0 && string(document.Fields) != "null" {
+		decoder := json.NewDecoder(bytes.NewReader(document.Fields))
+		if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+			return fmt.Errorf("forms fields must be object")
+		}
+
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+
+			var value string
+			if err = decoder.Decode(&value); err != nil {
+				return fmt.Errorf("form field [%s] must be string: %w", token, err)
+			}
+
+			formsData = append(formsData, FormData{FormName: token.(string), FormValue: value})
+		}
+	}
+
+	*forms = Forms{Url: document.Url, FormData: formsData, Expiration: document.Expiration}
+	return nil
+}
+
+// Values get the form fields as url.Values
+func (forms Forms) Values() url.Values {
+	values := make(url.Values, len(forms.FormData))
+	for _, formData := range forms.getUniqueFormData() {
+		values.Set(formData.FormName, formData.FormValue)
+	}
+
+	return values
+}
+
+// WriteMultipart write the form fields in order, followed by the file if file is not nil.
+// The writer is not closed, so the caller can get the content type with writer.FormDataContentType() and close it.
+func (forms Forms) WriteMultipart(writer *multipart.Writer, filename string, file io.Reader) error {
+	for _, formData := range forms.getUniqueFormData() {
+		if err := writer.WriteField(formData.FormName, formData.FormValue); err != nil {
+			return err
+		}
+	}
+
+	if file == nil {
+		return nil
+	}
+
+	// S3 ignore the fields after the file, so the file must be the last field
+	fileWriter, err := writer.CreateFormFile(FormFileField, filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fileWriter, file)
+	return err
+}
+
+// get the form data with unique names, the last value is used at the position of the first one
+func (forms Forms) getUniqueFormData() []FormData {
+	formsData := make([]FormData, 0, len(forms.FormData))
+	positions := make(map[string]int, len(forms.FormData))
+	for _, formData := range forms.FormData {
+		if position, ok := positions[formData.FormName]; ok {
+			formsData[position].FormValue = formData.FormValue
+			continue
+		}
+
+		positions[formData.FormName] = len(formsData)
+		formsData = append(formsData, formData)
+	}
+
+	return formsData
+}
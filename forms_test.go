@@ -0,0 +1,127 @@
+package s3Presign
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormsJSON(t *testing.T) {
+	forms := Forms{
+		Url: "https://sigv4examplebucket.s3.amazonaws.com/",
+		FormData: []FormData{
+			{FormName: "key", FormValue: "user/<test>.jpeg"},
+			{FormName: "acl", FormValue: "private"},
+			{FormName: "Content-Type", FormValue: "image/jpeg"},
+			{FormName: "acl", FormValue: "public-read"},
+		},
+		Expiration: time.Date(2015, 12, 30, 12, 0, 0, 0, time.UTC),
+	}
+
+	output, err := json.Marshal(forms)
+	if err != nil {
+		t.Fatalf("failed to encode forms: %s", err.Error())
+	}
+
+	expected := `{"url":"https://sigv4examplebucket.s3.amazonaws.com/","fields":{"key":"user/\u003ctest\u003e.jpeg",` +
+		`"acl":"public-read","Content-Type":"image/jpeg"},"expiration":"2015-12-30T12:00:00Z"}`
+	if string(output) != expected {
+		t.Errorf("forms should be encoded as %s not %s", expected, string(output))
+	}
+
+	var decoded Forms
+	if err = json.Unmarshal(output, &decoded); err != nil {
+		t.Fatalf("failed to decode forms: %s", err.Error())
+	}
+
+	if decoded.Url != forms.Url || !decoded.Expiration.Equal(forms.Expiration) {
+		t.Errorf("decoded forms should have the same url and expiration: %+v", decoded)
+	}
+
+	expectedFormData := []FormData{
+		{FormName: "key", FormValue: "user/<test>.jpeg"},
+		{FormName: "acl", FormValue: "public-read"},
+		{FormName: "Content-Type", FormValue: "image/jpeg"},
+	}
+	if len(decoded.FormData) != len(expectedFormData) {
+		t.Fatalf("decoded forms should have %d fields not %d", len(expectedFormData), len(decoded.FormData))
+	}
+
+	for idx, formData := range expectedFormData {
+		if decoded.FormData[idx] != formData {
+			t.Errorf("field %d should be %+v not %+v", idx, formData, decoded.FormData[idx])
+		}
+	}
+
+	// without expiration, the same shape as boto3 generate_presigned_post
+	output, _ = json.Marshal(Forms{Url: "https://example.com/"})
+	if string(output) != `{"url":"https://example.com/","fields":{}}` {
+		t.Errorf("empty forms encoded as %s", string(output))
+	}
+
+	for _, invalid := range []string{`{"fields":[]}`, `{"fields":{"key":1}}`, `[]`} {
+		if err = json.Unmarshal([]byte(invalid), &decoded); err == nil {
+			t.Errorf("%s should return error", invalid)
+		}
+	}
+}
+
+func TestFormsMultipart(t *testing.T) {
+	s3PolicyBase := getTestPolicy()
+	_, _, forms, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	values := forms.Values()
+	for _, formData := range forms.FormData {
+		if values.Get(formData.FormName) != formData.FormValue {
+			t.Errorf("value [%s] should be [%s]", formData.FormName, formData.FormValue)
+		}
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err = forms.WriteMultipart(writer, "test.jpeg", strings.NewReader("file content")); err != nil {
+		t.Fatalf("failed to write multipart: %s", err.Error())
+	}
+
+	_ = writer.Close()
+
+	reader := multipart.NewReader(body, writer.Boundary())
+	var names []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("invalid multipart: %s", err.Error())
+		}
+
+		content, _ := io.ReadAll(part)
+		names = append(names, part.FormName())
+		if part.FormName() == FormFileField {
+			if part.FileName() != "test.jpeg" || string(content) != "file content" {
+				t.Errorf("file should be test.jpeg with the content, got %s %s", part.FileName(), string(content))
+			}
+		} else if values.Get(part.FormName()) != string(content) {
+			t.Errorf("part [%s] should be [%s] not [%s]", part.FormName(), values.Get(part.FormName()), string(content))
+		}
+	}
+
+	if len(names) != len(forms.FormData)+1 || names[len(names)-1] != FormFileField {
+		t.Errorf("multipart should have the fields in order and the file last: %v", names)
+	}
+
+	for idx, formData := range forms.FormData {
+		if names[idx] != formData.FormName {
+			t.Errorf("part %d should be [%s] not [%s]", idx, formData.FormName, names[idx])
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package s3Presign
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// guardrail rules, used as GuardrailViolation.Rule
+const GuardrailMaxObjectSize = "max-object-size"
+const GuardrailRequireSSE = "require-sse"
+const GuardrailKeyPrefix = "key-prefix"
+const GuardrailAllowedAcl = "allowed-acl"
+const GuardrailMaxExpiration = "max-expiration"
+
+// Guardrails hard rules enforced by GeneratePolicyWithError, attach it to AwsConfig.Guardrails
+// or set it for all policies with SetGlobalGuardrails. Zero value of a rule means the rule is not used.
+type Guardrails struct {
+	// MaxObjectSize the policy must have content-length-range with maximum not more than this value
+	MaxObjectSize uint64
+
+	// RequireSSE the policy must have x-amz-server-side-encryption condition,
+	// the value must be one of AllowedSSE if it's not empty
+	RequireSSE bool
+	AllowedSSE []string
+
+	// KeyPrefixes allowed key prefixes for each bucket, the key condition must be inside one of the prefixes.
+	// The bucket is taken from the signed bucket condition, it must use "eq" and be listed.
+	KeyPrefixes map[string][]string
+
+	// AllowedAcls the acl condition must use "eq" with one of these values
+	AllowedAcls []string
+
+	// MaxExpiration maximum time between the signing date and the policy expiration
+	MaxExpiration time.Duration
+}
+
+type GuardrailViolation struct {
+	Rule    string
+	Field   string
+	Message string
+}
+
+// GuardrailError returned by GeneratePolicyWithError when the policy violates the guardrails
+type GuardrailError struct {
+	Violations []GuardrailViolation
+}
+
+func (guardrailError GuardrailError) Error() string {
+	messages := make([]string, 0, len(guardrailError.Violations))
+	for _, violation := range guardrailError.Violations {
+		messages = append(messages, fmt.Sprintf("[%s] %s: %s", violation.Rule, violation.Field, violation.Message))
+	}
+
+	return "policy violates guardrails: " + strings.Join(messages, "; ")
+}
+
+var globalGuardrails struct {
+	sync.RWMutex
+	guardrails *Guardrails
+}
+
+// SetGlobalGuardrails set guardrails enforced for every policy, in addition to AwsConfig.Guardrails.
+// Use nil to remove the global guardrails.
+func SetGlobalGuardrails(guardrails *Guardrails) {
+	globalGuardrails.Lock()
+	defer globalGuardrails.Unlock()
+
+	globalGuardrails.guardrails = guardrails
+}
+
+// GetGlobalGuardrails get guardrails set with SetGlobalGuardrails
+func GetGlobalGuardrails() *Guardrails {
+	globalGuardrails.RLock()
+	defer globalGuardrails.RUnlock()
+
+	return globalGuardrails.guardrails
+}
+
+// check the policy conditions with global guardrails and AwsConfig guardrails
+func (base *BaseS3Policy) checkGuardrails(conditions []Condition) error {
+	var violations []GuardrailViolation
+	for _, guardrails := range []*Guardrails{GetGlobalGuardrails(), base.AwsConfig.Guardrails} {
+		if guardrails != nil {
+			violations = append(violations, guardrails.Check(base, conditions)...)
+		}
+	}
+
+	if len(violations) > 0 {
+		return GuardrailError{Violations: violations}
+	}
+
+	return nil
+}
+
+// Check return all guardrails violated by the policy conditions
+func (guardrails Guardrails) Check(base *BaseS3Policy, conditions []Condition) (violations []GuardrailViolation) {
+	if guardrails.MaxObjectSize > 0 && !hasMatchingCondition(conditions, ConditionSpecifyingRange, func(condition Condition) bool {
+		return condition.ConditionUsed == ConditionSpecifyingRange && condition.PolicyStopRange <= guardrails.MaxObjectSize
+	}) {
+		violations = append(violations, GuardrailViolation{
+			Rule:    GuardrailMaxObjectSize,
+			Field:   ConditionSpecifyingRange,
+			Message: fmt.Sprintf("maximum object size must be set and not more than %d bytes", guardrails.MaxObjectSize),
+		})
+	}
+
+	if guardrails.RequireSSE && !hasMatchingCondition(conditions, "x-amz-server-side-encryption", func(condition Condition) bool {
+		return condition.ConditionUsed == ConditionMatchingExactMatch &&
+			(len(guardrails.AllowedSSE) == 0 || inStrings(guardrails.AllowedSSE, condition.PolicyValue))
+	}) {
+		violations = append(violations, GuardrailViolation{
+			Rule:    GuardrailRequireSSE,
+			Field:   "x-amz-server-side-encryption",
+			Message: fmt.Sprintf("server side encryption must be set with eq condition, allowed %v", guardrails.AllowedSSE),
+		})
+	}
+
+	if len(guardrails.KeyPrefixes) > 0 {
+		violations = append(violations, guardrails.checkKeyPrefixes(conditions)...)
+	}
+
+	if len(guardrails.AllowedAcls) > 0 {
+		for _, condition := range conditions {
+			if condition.Field != "acl" {
+				continue
+			}
+
+			if condition.ConditionUsed != ConditionMatchingExactMatch || !inStrings(guardrails.AllowedAcls, condition.PolicyValue) {
+				violations = append(violations, GuardrailViolation{
+					Rule:    GuardrailAllowedAcl,
+					Field:   "acl",
+					Message: fmt.Sprintf("acl %s [%s] is not allowed, allowed %v", condition.ConditionUsed, condition.PolicyValue, guardrails.AllowedAcls),
+				})
+			}
+		}
+	}
+
+	if guardrails.MaxExpiration > 0 {
+		if expiration := base.ExpiredDate.Sub(base.Date); expiration > guardrails.MaxExpiration {
+			violations = append(violations, GuardrailViolation{
+				Rule:    GuardrailMaxExpiration,
+				Field:   "expiration",
+				Message: fmt.Sprintf("policy is valid for %s, maximum is %s", expiration, guardrails.MaxExpiration),
+			})
+		}
+	}
+
+	return violations
+}
+
+// check the signed bucket conditions, and the key is inside the prefixes of the bucket.
+// The bucket is taken from the conditions, not from AwsConfig, because the conditions are signed.
+func (guardrails Guardrails) checkKeyPrefixes(conditions []Condition) (violations []GuardrailViolation) {
+	var buckets []string
+	for _, condition := range conditions {
+		if condition.Field != "bucket" {
+			continue
+		}
+
+		if condition.ConditionUsed != ConditionMatchingExactMatch {
+			violations = append(violations, GuardrailViolation{
+				Rule:    GuardrailKeyPrefix,
+				Field:   "bucket",
+				Message: fmt.Sprintf("bucket must use %s condition, got %s [%s]", ConditionMatchingExactMatch, condition.ConditionUsed, condition.PolicyValue),
+			})
+			continue
+		}
+
+		if !inStrings(buckets, condition.PolicyValue) {
+			buckets = append(buckets, condition.PolicyValue)
+		}
+	}
+
+	if len(buckets) == 0 && len(violations) == 0 {
+		violations = append(violations, GuardrailViolation{
+			Rule:    GuardrailKeyPrefix,
+			Field:   "bucket",
+			Message: fmt.Sprintf("bucket must be set with %s condition", ConditionMatchingExactMatch),
+		})
+	}
+
+	for _, bucket := range buckets {
+		prefixes, ok := guardrails.KeyPrefixes[bucket]
+		if !ok {
+			violations = append(violations, GuardrailViolation{
+				Rule:    GuardrailKeyPrefix,
+				Field:   "bucket",
+				Message: fmt.Sprintf("bucket [%s] is not allowed", bucket),
+			})
+			continue
+		}
+
+		if !hasMatchingCondition(conditions, "key", func(condition Condition) bool {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(condition.PolicyValue, prefix) {
+					return true
+				}
+			}
+
+			return false
+		}) {
+			violations = append(violations, GuardrailViolation{
+				Rule:    GuardrailKeyPrefix,
+				Field:   "key",
+				Message: fmt.Sprintf("key must be inside one of prefixes %v for bucket [%s]", prefixes, bucket),
+			})
+		}
+	}
+
+	return violations
+}
+
+// check if one of the field conditions is matching, all conditions must be passed by S3,
+// so one matching condition is enough to restrict the field
+func hasMatchingCondition(conditions []Condition, field string, isMatch func(condition Condition) bool) bool {
+	for _, condition := range conditions {
+		if condition.Field == field && isMatch(condition) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,98 @@
+package s3Presign
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGuardrails(t *testing.T) {
+	defaultData := getDefaultData()
+
+	awsConfig := defaultData.AwsConfig
+	awsConfig.Guardrails = &Guardrails{
+		MaxObjectSize: 10485760,
+		RequireSSE:    true,
+		AllowedSSE:    []string{"AES256", "aws:kms"},
+		KeyPrefixes:   map[string][]string{AwsBucket: {"user/"}},
+		AllowedAcls:   []string{"private"},
+		MaxExpiration: time.Hour,
+	}
+
+	s3PolicyBase := NewS3Policy(awsConfig)
+	s3PolicyBase.Date = defaultData.DateCreated
+	s3PolicyBase.SetExpirationDate(defaultData.DateCreated.Add(2 * time.Hour))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "tmp/")
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, "public-read")
+	s3PolicyBase.SetContentLengthPolicy(0, 104857600)
+
+	var guardrailError GuardrailError
+	_, _, _, err := s3PolicyBase.GeneratePolicyWithError()
+	if !errors.As(err, &guardrailError) {
+		t.Fatalf("policy should violate guardrails, got %v", err)
+	}
+
+	violations := map[string]string{}
+	for _, violation := range guardrailError.Violations {
+		violations[violation.Rule] = violation.Field
+	}
+
+	testViolations := map[string]string{
+		GuardrailMaxObjectSize: ConditionSpecifyingRange,
+		GuardrailRequireSSE:    "x-amz-server-side-encryption",
+		GuardrailKeyPrefix:     "key",
+		GuardrailAllowedAcl:    "acl",
+		GuardrailMaxExpiration: "expiration",
+	}
+
+	for rule, field := range testViolations {
+		if violations[rule] != field {
+			t.Errorf("rule [%s] should be violated on field [%s], got [%s]", rule, field, violations[rule])
+		}
+	}
+
+	s3PolicyBase.SetExpirationDate(defaultData.DateCreated.Add(time.Hour))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "user/user1/")
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, "private")
+	s3PolicyBase.SetContentLengthPolicy(0, 1048576)
+	s3PolicyBase.SetXAmz("x-amz-server-side-encryption", ConditionMatchingExactMatch, "AES256")
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err != nil {
+		t.Errorf("policy should not violate guardrails, got %s", err.Error())
+	}
+
+	// global guardrails is enforced together with AwsConfig guardrails
+	SetGlobalGuardrails(&Guardrails{KeyPrefixes: map[string][]string{"other-bucket": {"user/"}}})
+	defer SetGlobalGuardrails(nil)
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); !errors.As(err, &guardrailError) || guardrailError.Violations[0].Field != "bucket" {
+		t.Errorf("policy should violate global guardrails for bucket, got %v", err)
+	}
+}
+
+func TestGuardrailsSignedBucket(t *testing.T) {
+	defaultData := getDefaultData()
+
+	awsConfig := defaultData.AwsConfig
+	awsConfig.Guardrails = &Guardrails{KeyPrefixes: map[string][]string{AwsBucket: {"user/"}}}
+
+	// the bucket condition is signed, not the configured bucket
+	testPolicy := map[string]func(base *BaseS3Policy){
+		"any bucket": func(base *BaseS3Policy) {
+			base.SetBucketPolicy(ConditionMatchingStartWith, "")
+		},
+		"endpoint of other bucket": func(base *BaseS3Policy) {
+			base.Endpoint = "https://secret.s3.amazonaws.com/"
+			base.SetBucketPolicy(ConditionMatchingExactMatch, "secret")
+		},
+	}
+
+	for name, setPolicy := range testPolicy {
+		s3PolicyBase := NewS3Policy(awsConfig)
+		s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "user/user1/")
+		setPolicy(s3PolicyBase)
+
+		var guardrailError GuardrailError
+		if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); !errors.As(err, &guardrailError) || guardrailError.Violations[0].Field != "bucket" {
+			t.Errorf("policy with %s should violate guardrails for bucket, got %v", name, err)
+		}
+	}
+}
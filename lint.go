@@ -0,0 +1,286 @@
+package s3Presign
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const LintSeverityError = "error"
+const LintSeverityWarning = "warning"
+
+// lint codes, used as LintFinding.Code
+const LintCodeInvalidPolicy = "invalid-policy"
+const LintCodeMissingSizeCap = "missing-size-cap"
+const LintCodeUnboundedKeyPrefix = "unbounded-key-prefix"
+const LintCodePublicAcl = "public-acl"
+const LintCodeLongExpiration = "long-expiration"
+const LintCodePolicyTooLarge = "policy-too-large"
+const LintCodeConditionWithoutFormField = "condition-without-form-field"
+const LintCodeOpenRedirect = "open-redirect"
+
+// MaxPolicySize the maximum size of the base64 encoded policy, S3 limit the form fields
+// (excluding the file) to 20 KB.
+const MaxPolicySize = 20 * 1024
+
+// DefaultMaxExpiration default maximum time between the signing date and the policy expiration
+const DefaultMaxExpiration = time.Hour
+
+type LintFinding struct {
+	Code     string
+	Severity string
+	Field    string
+	Message  string
+}
+
+func (finding LintFinding) String() string {
+	if finding.Field == "" {
+		return fmt.Sprintf("%s [%s]: %s", finding.Severity, finding.Code, finding.Message)
+	}
+
+	return fmt.Sprintf("%s [%s] %s: %s", finding.Severity, finding.Code, finding.Field, finding.Message)
+}
+
+// LintRule check the policy conditions, Code and Severity is used for the findings that didn't set it.
+type LintRule struct {
+	Code     string
+	Severity string
+	Check    func(base *BaseS3Policy, conditions []Condition) []LintFinding
+}
+
+// LintError returned by GeneratePolicyWithError when StrictLint is enabled and the linter found error
+type LintError struct {
+	Findings []LintFinding
+}
+
+func (lintError LintError) Error() string {
+	messages := make([]string, 0, len(lintError.Findings))
+	for _, finding := range lintError.Findings {
+		messages = append(messages, finding.String())
+	}
+
+	return "policy rejected by linter: " + strings.Join(messages, "; ")
+}
+
+// DefaultLintRules all lint rules with default configuration
+func DefaultLintRules() []LintRule {
+	return []LintRule{
+		LintMissingSizeCapRule(),
+		LintUnboundedKeyPrefixRule(),
+		LintPublicAclRule(),
+		LintLongExpirationRule(DefaultMaxExpiration),
+		LintPolicyTooLargeRule(MaxPolicySize),
+		LintConditionWithoutFormFieldRule(),
+		LintOpenRedirectRule(),
+	}
+}
+
+// Lint check the policy for insecure or broken conditions, using DefaultLintRules if rules is empty.
+func Lint(base *BaseS3Policy, rules ...LintRule) []LintFinding {
+	prepared, policy, err := base.preparePolicy()
+	if err != nil {
+		return []LintFinding{{Code: LintCodeInvalidPolicy, Severity: LintSeverityError, Message: err.Error()}}
+	}
+
+	return prepared.lintConditions(policy.getConditions(), rules)
+}
+
+// SetStrictLint make GeneratePolicyWithError refuse the policy if the linter found error,
+// using DefaultLintRules if rules is empty.
+func (base *BaseS3Policy) SetStrictLint(strict bool, rules ...LintRule) *BaseS3Policy {
+	base.StrictLint = strict
+	base.LintRules = rules
+	return base
+}
+
+func (base *BaseS3Policy) lintConditions(conditions []Condition, rules []LintRule) (findings []LintFinding) {
+	if len(rules) == 0 {
+		rules = DefaultLintRules()
+	}
+
+	for _, rule := range rules {
+		for _, finding := range rule.Check(base, conditions) {
+			if finding.Code == "" {
+				finding.Code = rule.Code
+			}
+
+			if finding.Severity == "" {
+				finding.Severity = rule.Severity
+			}
+
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+// return LintError if there is finding with error severity
+func getLintError(findings []LintFinding) error {
+	var errorFindings []LintFinding
+	for _, finding := range findings {
+		if finding.Severity == LintSeverityError {
+			errorFindings = append(errorFindings, finding)
+		}
+	}
+
+	if len(errorFindings) == 0 {
+		return nil
+	}
+
+	return LintError{Findings: errorFindings}
+}
+
+// LintMissingSizeCapRule the policy must have content-length-range condition,
+// without it the client can upload any size up to 5 GB.
+func LintMissingSizeCapRule() LintRule {
+	return LintRule{
+		Code:     LintCodeMissingSizeCap,
+		Severity: LintSeverityError,
+		Check: func(base *BaseS3Policy, conditions []Condition) []LintFinding {
+			for _, condition := range conditions {
+				if condition.ConditionUsed == ConditionSpecifyingRange {
+					return nil
+				}
+			}
+
+			return []LintFinding{{Field: ConditionSpecifyingRange, Message: "policy doesn't limit the upload size"}}
+		},
+	}
+}
+
+// LintUnboundedKeyPrefixRule the key must not use starts-with empty value, it allows the client to write any key in the bucket.
+func LintUnboundedKeyPrefixRule() LintRule {
+	return LintRule{
+		Code:     LintCodeUnboundedKeyPrefix,
+		Severity: LintSeverityError,
+		Check: func(base *BaseS3Policy, conditions []Condition) []LintFinding {
+			for _, condition := range conditions {
+				if condition.Field != "key" {
+					continue
+				}
+
+				if condition.ConditionUsed == ConditionMatchingExactMatch ||
+					(condition.ConditionUsed == ConditionMatchingStartWith && strings.Trim(condition.PolicyValue, "/") != "") {
+					return nil
+				}
+			}
+
+			return []LintFinding{{Field: "key", Message: "key can be any object in the bucket, use eq or starts-with a prefix"}}
+		},
+	}
+}
+
+// LintPublicAclRule the acl must not allow public access
+func LintPublicAclRule() LintRule {
+	publicAcl := []string{"public-read", "public-read-write", "authenticated-read"}
+	return LintRule{
+		Code:     LintCodePublicAcl,
+		Severity: LintSeverityWarning,
+		Check: func(base *BaseS3Policy, conditions []Condition) (findings []LintFinding) {
+			for _, condition := range conditions {
+				if condition.Field != "acl" {
+					continue
+				}
+
+				switch {
+				case condition.ConditionUsed == ConditionMatchingExactMatch && inStrings(publicAcl, condition.PolicyValue):
+					findings = append(findings, LintFinding{Field: "acl", Message: fmt.Sprintf("acl [%s] make the object public", condition.PolicyValue)})
+				case condition.ConditionUsed == ConditionMatchingStartWith:
+					findings = append(findings, LintFinding{Field: "acl", Message: fmt.Sprintf("acl starts with [%s] allows the client to choose public acl", condition.PolicyValue)})
+				}
+			}
+
+			return findings
+		},
+	}
+}
+
+// LintLongExpirationRule the policy must not be valid more than maxExpiration from the signing date
+func LintLongExpirationRule(maxExpiration time.Duration) LintRule {
+	return LintRule{
+		Code:     LintCodeLongExpiration,
+		Severity: LintSeverityWarning,
+		Check: func(base *BaseS3Policy, conditions []Condition) []LintFinding {
+			expiration := base.ExpiredDate.Sub(base.Date)
+			if expiration <= maxExpiration {
+				return nil
+			}
+
+			return []LintFinding{{Field: "expiration", Message: fmt.Sprintf("policy is valid for %s, maximum is %s", expiration, maxExpiration)}}
+		},
+	}
+}
+
+// LintPolicyTooLargeRule the base64 encoded policy must not be more than maxSize bytes
+func LintPolicyTooLargeRule(maxSize int) LintRule {
+	return LintRule{
+		Code:     LintCodePolicyTooLarge,
+		Severity: LintSeverityError,
+		Check: func(base *BaseS3Policy, conditions []Condition) []LintFinding {
+			policyDocument := encodePolicyDocument(base.ExpiredDate.UTC().Format(ExpirationFormat), conditions)
+			size := base64.StdEncoding.EncodedLen(len(policyDocument))
+			if size <= maxSize {
+				return nil
+			}
+
+			return []LintFinding{{Field: "policy", Message: fmt.Sprintf("encoded policy is %d bytes, maximum is %d bytes", size, maxSize)}}
+		},
+	}
+}
+
+// LintConditionWithoutFormFieldRule the conditions on fields that are not added to the form,
+// the client must add the field to the form, otherwise S3 will reject the upload.
+func LintConditionWithoutFormFieldRule() LintRule {
+	return LintRule{
+		Code:     LintCodeConditionWithoutFormField,
+		Severity: LintSeverityWarning,
+		Check: func(base *BaseS3Policy, conditions []Condition) (findings []LintFinding) {
+			for _, condition := range conditions {
+				if condition.ConditionUsed == ConditionSpecifyingRange {
+					continue
+				}
+
+				if policyField, ok := GetPolicyField(condition.Field); ok && policyField.FormField {
+					continue
+				}
+
+				findings = append(findings, LintFinding{Field: condition.Field, Message: "condition field is not added to the form, the client must send it"})
+			}
+
+			return findings
+		},
+	}
+}
+
+// LintOpenRedirectRule the success_action_redirect must redirect to allowed hosts,
+// if allowedHosts is empty the redirect host only need to be fixed by the condition.
+func LintOpenRedirectRule(allowedHosts ...string) LintRule {
+	return LintRule{
+		Code:     LintCodeOpenRedirect,
+		Severity: LintSeverityWarning,
+		Check: func(base *BaseS3Policy, conditions []Condition) (findings []LintFinding) {
+			for _, condition := range conditions {
+				if condition.Field != "success_action_redirect" || condition.ConditionUsed == ConditionSpecifyingRange {
+					continue
+				}
+
+				redirectUrl, err := url.Parse(condition.PolicyValue)
+				isFixedHost := err == nil && redirectUrl.Host != "" &&
+					(condition.ConditionUsed == ConditionMatchingExactMatch || strings.HasPrefix(redirectUrl.EscapedPath(), "/"))
+				if !isFixedHost {
+					findings = append(findings, LintFinding{Field: condition.Field, Message: fmt.Sprintf("redirect [%s] can go to any host", condition.PolicyValue)})
+					continue
+				}
+
+				if len(allowedHosts) > 0 && !inStrings(allowedHosts, redirectUrl.Hostname()) {
+					findings = append(findings, LintFinding{Field: condition.Field, Message: fmt.Sprintf("redirect host [%s] is not allowed", redirectUrl.Hostname())})
+				}
+			}
+
+			return findings
+		},
+	}
+}
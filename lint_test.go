@@ -0,0 +1,84 @@
+package s3Presign
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLint(t *testing.T) {
+	defaultData := getDefaultData()
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.Date = defaultData.DateCreated
+	s3PolicyBase.SetExpirationDate(defaultData.DateCreated.Add(7 * 24 * time.Hour))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "")
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, "public-read")
+	s3PolicyBase.SetSuccessActionRedirectPolicy(ConditionMatchingStartWith, "https://")
+	s3PolicyBase.SetXAmz("x-amz-custom", ConditionMatchingExactMatch, "value")
+
+	findings := map[string]string{}
+	for _, finding := range Lint(s3PolicyBase) {
+		findings[finding.Code] = finding.Severity
+	}
+
+	testFindings := map[string]string{
+		LintCodeMissingSizeCap:            LintSeverityError,
+		LintCodeUnboundedKeyPrefix:        LintSeverityError,
+		LintCodePublicAcl:                 LintSeverityWarning,
+		LintCodeLongExpiration:            LintSeverityWarning,
+		LintCodeConditionWithoutFormField: LintSeverityWarning,
+		LintCodeOpenRedirect:              LintSeverityWarning,
+	}
+
+	for code, severity := range testFindings {
+		if findings[code] != severity {
+			t.Errorf("finding [%s] should have severity [%s] not [%s]", code, severity, findings[code])
+		}
+	}
+
+	if _, ok := findings[LintCodePolicyTooLarge]; ok {
+		t.Errorf("policy should not be too large")
+	}
+
+	var lintError LintError
+	s3PolicyBase.SetStrictLint(true)
+	if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); !errors.As(err, &lintError) || len(lintError.Findings) != 2 {
+		t.Errorf("strict lint should return LintError with 2 findings, got %v", err)
+	}
+
+	// only use the configured rules
+	s3PolicyBase.SetStrictLint(true, LintPublicAclRule(), LintOpenRedirectRule("example.com"))
+	if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err != nil {
+		t.Errorf("strict lint without error rules should not return error, got %s", err.Error())
+	}
+
+	// lint doesn't change the policy
+	s3PolicyBase = NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "test.txt")
+	before, _ := s3PolicyBase.State()
+	Lint(s3PolicyBase)
+	if after, _ := s3PolicyBase.State(); !reflect.DeepEqual(before, after) || s3PolicyBase.Policy.Bucket.ConditionUsed != "" {
+		t.Errorf("lint should not change the policy: %+v", after)
+	}
+}
+
+func TestLintOpenRedirect(t *testing.T) {
+	testRedirect := map[string]bool{
+		"https://example.com/uploaded":     true,
+		"https://example.com":              false, // prefix can be https://example.com.evil.com
+		"https://evil.com/uploaded":        false, // not allowed host
+		"//example.com/uploaded":           true,
+		"/uploaded":                        false,
+		"https://www.example.com/uploaded": false,
+	}
+
+	rule := LintOpenRedirectRule("example.com")
+	for redirect, isValid := range testRedirect {
+		conditions := []Condition{{Field: "success_action_redirect", ConditionUsed: ConditionMatchingStartWith, PolicyValue: redirect}}
+		if findings := rule.Check(nil, conditions); (len(findings) == 0) != isValid {
+			t.Errorf("redirect [%s] should be valid [%t], got findings %v", redirect, isValid, findings)
+		}
+	}
+}
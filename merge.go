@@ -0,0 +1,171 @@
+package s3Presign
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type MergeConflict struct {
+	Field   string
+	Message string
+}
+
+// MergeConflictError returned by Merge and GeneratePolicyWithError when no upload can pass the conditions
+type MergeConflictError struct {
+	Conflicts []MergeConflict
+}
+
+func (mergeError MergeConflictError) Error() string {
+	messages := make([]string, 0, len(mergeError.Conflicts))
+	for _, conflict := range mergeError.Conflicts {
+		messages = append(messages, fmt.Sprintf("%s: %s", conflict.Field, conflict.Message))
+	}
+
+	return "policy has conflicting conditions: " + strings.Join(messages, "; ")
+}
+
+// Merge create a new policy from the base with the overrides applied in order, the base and the overrides are not changed.
+// Only the conditions and AwsConfig.AwsBucket of the overrides are used, the other settings are from the base.
+//   - policy fields (key, acl, Content-Type, ...) are replaced by the override, Content-Type also replace the allowed content types
+//   - x-amz-meta-* and x-amz-* are merged, the override replace the same name
+//   - content-length-range use the strictest range of the base and the overrides
+//   - conditions added with AddCondition and AddRange are added
+//
+// The conditions generated on signing are not merged. MergeConflictError is returned when no upload can pass
+// the merged conditions, ex: different "eq" values of the same field, or content-length-range without intersection.
+func (base *BaseS3Policy) Merge(overrides ...*BaseS3Policy) (*BaseS3Policy, error) {
+	merged := base.Clone()
+	if merged.Policy == nil {
+		merged.Policy = &Policy{}
+	}
+
+	for _, override := range overrides {
+		if override.AwsConfig.AwsBucket != "" {
+			merged.AwsConfig.AwsBucket = override.AwsConfig.AwsBucket
+		}
+
+		if override.Policy != nil {
+			merged.Policy.merge(override.Policy)
+		}
+	}
+
+	if conflicts := merged.Policy.getConflicts(); len(conflicts) > 0 {
+		return nil, MergeConflictError{Conflicts: conflicts}
+	}
+
+	return merged, nil
+}
+
+func (policy *Policy) merge(override *Policy) {
+	overrideFields := override.getFields()
+	for idx, field := range policy.getFields() {
+		overrideCondition := *overrideFields[idx].condition
+		if overrideCondition.ConditionUsed == "" || isSigningCondition(field.name) || field.name == "x-amz-security-token" {
+			continue
+		}
+
+		if field.name == ConditionSpecifyingRange && field.condition.ConditionUsed == ConditionSpecifyingRange {
+			// strictest range
+			if overrideCondition.PolicyStartRange < field.condition.PolicyStartRange {
+				overrideCondition.PolicyStartRange = field.condition.PolicyStartRange
+			}
+
+			if overrideCondition.PolicyStopRange > field.condition.PolicyStopRange {
+				overrideCondition.PolicyStopRange = field.condition.PolicyStopRange
+			}
+		}
+
+		*field.condition = overrideCondition
+	}
+
+	policy.XAmzMeta = mergePolicyConditions(policy.XAmzMeta, override.XAmzMeta)
+	policy.XAmz = mergePolicyConditions(policy.XAmz, override.XAmz)
+	policy.Conditions = append(policy.Conditions, override.Conditions...)
+	// the allowed content types are replaced with the Content-Type condition
+	if len(override.AllowedContentTypes) > 0 || override.ContentType.ConditionUsed != "" {
+		policy.AllowedContentTypes = append([]string(nil), override.AllowedContentTypes...)
+	}
+}
+
+func mergePolicyConditions(policyConditions, override map[string]PolicyConditions) map[string]PolicyConditions {
+	if len(override) == 0 {
+		return policyConditions
+	}
+
+	if policyConditions == nil {
+		policyConditions = make(map[string]PolicyConditions, len(override))
+	}
+
+	for name, condition := range override {
+		policyConditions[name] = condition
+	}
+
+	return policyConditions
+}
+
+// get the conditions that can't be passed together, S3 field names are case-insensitive
+func (policy *Policy) getConflicts() (conflicts []MergeConflict) {
+	fieldConditions := map[string][]Condition{}
+	for _, condition := range policy.getConditions() {
+		field := strings.ToLower(condition.Field)
+		fieldConditions[field] = append(fieldConditions[field], condition)
+	}
+
+	fields := make([]string, 0, len(fieldConditions))
+	for field := range fieldConditions {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+	for _, field := range fields {
+		if message := getConditionsConflict(fieldConditions[field]); message != "" {
+			conflicts = append(conflicts, MergeConflict{Field: field, Message: message})
+		}
+	}
+
+	return conflicts
+}
+
+func getConditionsConflict(conditions []Condition) string {
+	var exactValue, prefix string
+	var hasExactValue, hasRange bool
+	var minSize, maxSize uint64
+	for _, condition := range conditions {
+		switch condition.ConditionUsed {
+		case ConditionSpecifyingRange:
+			if !hasRange || condition.PolicyStartRange > minSize {
+				minSize = condition.PolicyStartRange
+			}
+
+			if !hasRange || condition.PolicyStopRange < maxSize {
+				maxSize = condition.PolicyStopRange
+			}
+
+			hasRange = true
+		case ConditionMatchingExactMatch:
+			if hasExactValue && condition.PolicyValue != exactValue {
+				return fmt.Sprintf("must be both [%s] and [%s]", exactValue, condition.PolicyValue)
+			}
+
+			exactValue, hasExactValue = condition.PolicyValue, true
+		case ConditionMatchingStartWith:
+			switch {
+			case strings.HasPrefix(condition.PolicyValue, prefix):
+				prefix = condition.PolicyValue
+			case !strings.HasPrefix(prefix, condition.PolicyValue):
+				return fmt.Sprintf("must start with both [%s] and [%s]", prefix, condition.PolicyValue)
+			}
+		}
+	}
+
+	if hasExactValue && !strings.HasPrefix(exactValue, prefix) {
+		return fmt.Sprintf("must be [%s] and start with [%s]", exactValue, prefix)
+	}
+
+	if hasRange && minSize > maxSize {
+		return fmt.Sprintf("size range %d-%d is empty", minSize, maxSize)
+	}
+
+	return ""
+}
@@ -0,0 +1,133 @@
+package s3Presign
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	defaultData := getDefaultData()
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "tenants/")
+	s3PolicyBase.SetContentLengthPolicy(1, 10485760)
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, "private")
+	s3PolicyBase.SetXAmzMeta("env", ConditionMatchingExactMatch, "prod")
+	s3PolicyBase.SetXAmzMeta("tenant", ConditionMatchingStartWith, "")
+	baseState, _ := s3PolicyBase.State()
+
+	tenant := NewS3Policy(AwsConfig{AwsBucket: "tenant-bucket"})
+	tenant.SetKeyPolicy(ConditionMatchingStartWith, "tenants/acme/")
+	tenant.SetContentLengthPolicy(1024, 52428800)
+	tenant.SetXAmzMeta("tenant", ConditionMatchingExactMatch, "acme")
+	tenant.AddCondition("x-ignore-tracking", ConditionMatchingExactMatch, "1")
+	tenantState, _ := tenant.State()
+
+	merged, err := s3PolicyBase.Merge(tenant)
+	if err != nil {
+		t.Fatalf("failed to merge policies: %s", err.Error())
+	}
+
+	if merged.AwsConfig.AwsBucket != "tenant-bucket" || merged.AwsConfig.AwsSecretKey != defaultData.AwsConfig.AwsSecretKey {
+		t.Errorf("merged policy should use the tenant bucket and the base credentials: %+v", merged.AwsConfig)
+	}
+
+	if merged.Policy.Key.PolicyValue != "tenants/acme/" || merged.Policy.Acl.PolicyValue != "private" {
+		t.Errorf("merged key should be replaced and acl kept: %+v %+v", merged.Policy.Key, merged.Policy.Acl)
+	}
+
+	// strictest range
+	if merged.Policy.ContentLengthRange.PolicyStartRange != 1024 || merged.Policy.ContentLengthRange.PolicyStopRange != 10485760 {
+		t.Errorf("merged range should be 1024-10485760 not %+v", merged.Policy.ContentLengthRange)
+	}
+
+	expectedMeta := map[string]PolicyConditions{
+		"x-amz-meta-env":    {ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "prod"},
+		"x-amz-meta-tenant": {ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "acme"},
+	}
+	if !reflect.DeepEqual(merged.Policy.XAmzMeta, expectedMeta) || len(merged.Policy.Conditions) != 1 {
+		t.Errorf("merged metadata should be %+v not %+v", expectedMeta, merged.Policy.XAmzMeta)
+	}
+
+	_, _, formsData, err := merged.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate merged policy: %s", err.Error())
+	}
+
+	if !hasFormValue(formsData, "bucket", "tenant-bucket") {
+		t.Errorf("merged bucket condition should be tenant-bucket: %+v", formsData.FormData)
+	}
+
+	// the inputs are not changed
+	if state, _ := s3PolicyBase.State(); !reflect.DeepEqual(state, baseState) {
+		t.Errorf("base policy should not be changed: %+v", state)
+	}
+
+	if state, _ := tenant.State(); !reflect.DeepEqual(state, tenantState) {
+		t.Errorf("override policy should not be changed: %+v", state)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	defaultData := getDefaultData()
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetContentLengthPolicy(1, 1048576)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "tenants/")
+	s3PolicyBase.AddCondition("acl", ConditionMatchingExactMatch, "private")
+
+	tenant := NewS3Policy(AwsConfig{})
+	tenant.SetContentLengthPolicy(2097152, 5242880)
+	tenant.SetAclPolicy(ConditionMatchingExactMatch, "public-read")
+	tenant.AddCondition("key", ConditionMatchingStartWith, "shared/")
+
+	_, err := s3PolicyBase.Merge(tenant)
+
+	var mergeError MergeConflictError
+	if !errors.As(err, &mergeError) {
+		t.Fatalf("merge should return MergeConflictError, got %v", err)
+	}
+
+	expected := []MergeConflict{
+		{Field: "acl", Message: "must be both [public-read] and [private]"},
+		{Field: "content-length-range", Message: "size range 2097152-1048576 is empty"},
+		{Field: "key", Message: "must start with both [tenants/] and [shared/]"},
+	}
+	if !reflect.DeepEqual(mergeError.Conflicts, expected) {
+		t.Errorf("conflicts should be %+v not %+v", expected, mergeError.Conflicts)
+	}
+
+	tenant = NewS3Policy(AwsConfig{})
+	tenant.SetKeyPolicy(ConditionMatchingExactMatch, "shared/file.txt")
+	tenant.AddCondition("key", ConditionMatchingStartWith, "tenants/")
+	if _, err = s3PolicyBase.Merge(tenant); !errors.As(err, &mergeError) || mergeError.Conflicts[0].Field != "key" {
+		t.Errorf("key outside of the prefix should conflict, got %v", err)
+	}
+}
+
+func TestMergeContentType(t *testing.T) {
+	defaultData := getDefaultData()
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "uploads/")
+	s3PolicyBase.AllowContentTypes([]string{"image/png", "image/jpeg"})
+
+	tenant := NewS3Policy(AwsConfig{})
+	tenant.SetContentTypePolicy(ConditionMatchingExactMatch, "application/pdf")
+
+	merged, err := s3PolicyBase.Merge(tenant)
+	if err != nil {
+		t.Fatalf("failed to merge policy: %s", err.Error())
+	}
+
+	if len(merged.Policy.AllowedContentTypes) != 0 || merged.Policy.ContentType.PolicyValue != "application/pdf" {
+		t.Errorf("Content-Type override should replace the allowed content types: %+v", merged.Policy.AllowedContentTypes)
+	}
+
+	_, _, formsData, err := merged.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate merged policy: %s", err.Error())
+	}
+
+	if !hasFormValue(formsData, "Content-Type", "application/pdf") {
+		t.Errorf("merged policy should allow application/pdf: %+v", formsData.FormData)
+	}
+}
@@ -0,0 +1,276 @@
+package s3Presign
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// XAmzMetaTag struct tag of the x-amz-meta-* fields, the format is "name,condition" where condition is
+// ConditionMatchingExactMatch (default) or ConditionMatchingStartWith, add ",omitempty" to skip the empty value, ex:
+//
+//	type UploadMeta struct {
+//		Tenant   string `s3meta:"tenant,eq"`
+//		Source   string `s3meta:"source,starts-with"`
+//		Uploader int64  `s3meta:"uploader-id"`
+//		Note     string `s3meta:"note,eq,omitempty"`
+//	}
+//
+// The field type can be string, bool, int, uint, float or implement encoding.TextMarshaler and encoding.TextUnmarshaler.
+// The fields of embedded structs without tag are used too, the names are case-insensitive and must be unique.
+const XAmzMetaTag = "s3meta"
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+type xAmzMetaField struct {
+	index         []int
+	name          string
+	conditionUsed string
+	omitEmpty     bool
+}
+
+// SetXAmzMetaStruct set the x-amz-meta-* conditions from the struct fields with XAmzMetaTag tag,
+// the conditions are sent as form fields like SetXAmzMeta. value can be struct or pointer to struct.
+func (base *BaseS3Policy) SetXAmzMetaStruct(value interface{}) error {
+	structValue := reflect.Indirect(reflect.ValueOf(value))
+	if structValue.Kind() != reflect.Struct {
+		return fmt.Errorf("x-amz-meta value must be struct, got %T", value)
+	}
+
+	fields, err := getXAmzMetaFields(structValue.Type())
+	if err != nil {
+		return err
+	}
+
+	xAmzMeta := map[string]PolicyConditions{}
+	for _, field := range fields {
+		fieldValue := structValue.FieldByIndex(field.index)
+		if field.omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+
+		formatted, err := formatXAmzMetaValue(fieldValue)
+		if err != nil {
+			return fmt.Errorf("x-amz-meta [%s]: %w", field.name, err)
+		}
+
+		xAmzMeta[field.name] = PolicyConditions{ConditionUsed: field.conditionUsed, PolicyValue: formatted}
+	}
+
+	// the conditions are checked by getXAmzMetaFields, only set them when all values can be formatted
+	for name, condition := range xAmzMeta {
+		base.SetXAmzMeta(name, condition.ConditionUsed, condition.PolicyValue)
+	}
+
+	return nil
+}
+
+// DecodeXAmzMetaStruct read the user-defined metadata from the object headers (ex: HeadObject response) to the struct
+// fields with XAmzMetaTag tag, out must be pointer to struct. The fields without header are not changed.
+func DecodeXAmzMetaStruct(header http.Header, out interface{}) error {
+	return decodeXAmzMetaStruct(header, out)
+}
+
+// DecodeXAmzMetaForm read the x-amz-meta-* form values (ex: verified upload form) to the struct
+// fields with XAmzMetaTag tag, out must be pointer to struct. The fields without form value are not changed.
+func DecodeXAmzMetaForm(values url.Values, out interface{}) error {
+	return decodeXAmzMetaStruct(values, out)
+}
+
+func decodeXAmzMetaStruct(fields map[string][]string, out interface{}) error {
+	outValue := reflect.ValueOf(out)
+	if outValue.Kind() != reflect.Ptr || outValue.IsNil() || outValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("x-amz-meta output must be pointer to struct, got %T", out)
+	}
+
+	structValue := outValue.Elem()
+	xAmzMetaFields, err := getXAmzMetaFields(structValue.Type())
+	if err != nil {
+		return err
+	}
+
+	xAmzMeta, err := decodeXAmzMetaValues(fields)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range xAmzMetaFields {
+		value, ok := xAmzMeta[field.name]
+		if !ok {
+			continue
+		}
+
+		if err = parseXAmzMetaValue(structValue.FieldByIndex(field.index), value); err != nil {
+			return fmt.Errorf("x-amz-meta [%s]: %w", field.name, err)
+		}
+	}
+
+	return nil
+}
+
+// get the struct fields with XAmzMetaTag tag, the names are lowercase like the decoded headers.
+// Return error if more than one field has the same name.
+func getXAmzMetaFields(structType reflect.Type) ([]xAmzMetaField, error) {
+	fields, err := appendXAmzMetaFields(nil, structType, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if names[field.name] {
+			return nil, fmt.Errorf("x-amz-meta name [%s] is used by more than one field", field.name)
+		}
+
+		names[field.name] = true
+	}
+
+	return fields, nil
+}
+
+func appendXAmzMetaFields(fields []xAmzMetaField, structType reflect.Type, parentIndex []int) ([]xAmzMetaField, error) {
+	for idx := 0; idx < structType.NumField(); idx++ {
+		structField := structType.Field(idx)
+		index := append(append([]int(nil), parentIndex...), idx)
+		tag, ok := structField.Tag.Lookup(XAmzMetaTag)
+		if structField.Anonymous && !ok {
+			switch structField.Type.Kind() {
+			case reflect.Struct:
+				var err error
+				if fields, err = appendXAmzMetaFields(fields, structField.Type, index); err != nil {
+					return nil, err
+				}
+			case reflect.Ptr:
+				if structField.Type.Elem().Kind() == reflect.Struct {
+					return nil, fmt.Errorf("x-amz-meta embedded field [%s] is pointer, embed the struct by value", structField.Name)
+				}
+			}
+
+			continue
+		}
+
+		if !ok || tag == "-" {
+			continue
+		}
+
+		if structField.PkgPath != "" {
+			return nil, fmt.Errorf("x-amz-meta field [%s] is not exported", structField.Name)
+		}
+
+		options := strings.Split(tag, ",")
+		field := xAmzMetaField{
+			index:         index,
+			name:          strings.ToLower(strings.TrimSpace(options[0])),
+			conditionUsed: ConditionMatchingExactMatch,
+		}
+
+		if field.name == "" {
+			field.name = strings.ToLower(structField.Name)
+		}
+
+		for _, option := range options[1:] {
+			switch option = strings.TrimSpace(option); option {
+			case ConditionMatchingExactMatch, ConditionMatchingStartWith:
+				field.conditionUsed = option
+			case "omitempty":
+				field.omitEmpty = true
+			default:
+				return nil, fmt.Errorf("x-amz-meta field [%s] has unknown tag option [%s]", structField.Name, option)
+			}
+		}
+
+		if !checkConditions(getFieldConditions(XAmzMetaKey+field.name), field.conditionUsed) {
+			return nil, fmt.Errorf("x-amz-meta field [%s] can't use condition [%s]", structField.Name, field.conditionUsed)
+		}
+
+		if !isValidXAmzMetaType(structField.Type) {
+			return nil, fmt.Errorf("x-amz-meta field [%s] type %s is not supported", structField.Name, structField.Type)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func isValidXAmzMetaType(fieldType reflect.Type) bool {
+	if fieldType.Implements(textMarshalerType) && reflect.PtrTo(fieldType).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func formatXAmzMetaValue(value reflect.Value) (string, error) {
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits()), nil
+	default:
+		return value.String(), nil
+	}
+}
+
+func parseXAmzMetaValue(field reflect.Value, value string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(parsed)
+	default:
+		field.SetString(value)
+	}
+
+	return nil
+}
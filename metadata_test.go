@@ -0,0 +1,159 @@
+package s3Presign
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testUploadMeta struct {
+	Tenant     string    `s3meta:"tenant,eq"`
+	Source     string    `s3meta:"source,starts-with"`
+	UploaderID int64     `s3meta:"uploader-id"`
+	Public     bool      `s3meta:"public"`
+	UploadedAt time.Time `s3meta:"uploaded-at"`
+	Note       string    `s3meta:"note,eq,omitempty"`
+	Ignored    string
+	Skipped    string `s3meta:"-"`
+}
+
+func TestSetXAmzMetaStruct(t *testing.T) {
+	defaultData := getDefaultData()
+	meta := testUploadMeta{
+		Tenant:     "café",
+		Source:     "mobile/",
+		UploaderID: 42,
+		Public:     true,
+		UploadedAt: defaultData.DateCreated,
+		Ignored:    "ignored",
+		Skipped:    "skipped",
+	}
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "test.txt")
+	s3PolicyBase.SetXAmzMetaEncoding(true)
+	if err := s3PolicyBase.SetXAmzMetaStruct(&meta); err != nil {
+		t.Fatalf("failed to set x-amz-meta struct: %s", err.Error())
+	}
+
+	expected := map[string]PolicyConditions{
+		"x-amz-meta-tenant":      {ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "café"},
+		"x-amz-meta-source":      {ConditionUsed: ConditionMatchingStartWith, PolicyValue: "mobile/"},
+		"x-amz-meta-uploader-id": {ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "42"},
+		"x-amz-meta-public":      {ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "true"},
+		"x-amz-meta-uploaded-at": {ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "2015-12-29T00:00:00Z"},
+	}
+	if !reflect.DeepEqual(s3PolicyBase.Policy.XAmzMeta, expected) {
+		t.Errorf("x-amz-meta should be %+v not %+v", expected, s3PolicyBase.Policy.XAmzMeta)
+	}
+
+	_, _, forms, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	// the uploaded form and the object headers are decoded to the same struct
+	values := forms.Values()
+	values.Set("x-amz-meta-source", "mobile/ios")
+
+	var formMeta testUploadMeta
+	if err = DecodeXAmzMetaForm(values, &formMeta); err != nil {
+		t.Fatalf("failed to decode form: %s", err.Error())
+	}
+
+	header := http.Header{}
+	for name, value := range values {
+		header.Set(name, value[0])
+	}
+
+	var headerMeta testUploadMeta
+	if err = DecodeXAmzMetaStruct(header, &headerMeta); err != nil {
+		t.Fatalf("failed to decode headers: %s", err.Error())
+	}
+
+	meta.Source, meta.Ignored, meta.Skipped = "mobile/ios", "", ""
+	for _, decoded := range []testUploadMeta{formMeta, headerMeta} {
+		if !reflect.DeepEqual(decoded, meta) {
+			t.Errorf("decoded metadata should be %+v not %+v", meta, decoded)
+		}
+	}
+}
+
+func TestXAmzMetaStructInvalid(t *testing.T) {
+	s3PolicyBase := NewS3Policy(AwsConfig{})
+	invalidValues := []interface{}{
+		"not struct",
+		struct {
+			Tenant string `s3meta:"tenant,in"`
+		}{},
+		struct {
+			Tags []string `s3meta:"tags"`
+		}{},
+		struct {
+			tenant string `s3meta:"tenant"`
+		}{},
+		struct {
+			Tenant string `s3meta:"tenant"`
+			Owner  string `s3meta:"Tenant"`
+		}{},
+		struct {
+			testUploadMeta
+			Tenant string `s3meta:"tenant"`
+		}{},
+		struct {
+			*testUploadMeta
+		}{testUploadMeta: &testUploadMeta{}},
+	}
+
+	for _, value := range invalidValues {
+		if err := s3PolicyBase.SetXAmzMetaStruct(value); err == nil {
+			t.Errorf("%+v should return error", value)
+		}
+	}
+
+	if len(s3PolicyBase.Policy.XAmzMeta) > 0 {
+		t.Errorf("invalid struct should not set x-amz-meta: %+v", s3PolicyBase.Policy.XAmzMeta)
+	}
+
+	header := http.Header{"X-Amz-Meta-Uploader-Id": {"not number"}}
+	var meta testUploadMeta
+	if err := DecodeXAmzMetaStruct(header, &meta); err == nil {
+		t.Errorf("invalid number should return error")
+	}
+
+	if err := DecodeXAmzMetaStruct(header, meta); err == nil {
+		t.Errorf("non pointer output should return error")
+	}
+}
+
+func TestXAmzMetaStructEmbedded(t *testing.T) {
+	type embeddedMeta struct {
+		testUploadMeta
+		Album string `s3meta:"album"`
+	}
+
+	meta := embeddedMeta{Album: "holiday"}
+	meta.Tenant = "tenant1"
+
+	s3PolicyBase := NewS3Policy(AwsConfig{})
+	if err := s3PolicyBase.SetXAmzMetaStruct(meta); err != nil {
+		t.Fatalf("failed to set x-amz-meta struct: %s", err.Error())
+	}
+
+	for name, value := range map[string]string{"x-amz-meta-tenant": "tenant1", "x-amz-meta-album": "holiday"} {
+		if s3PolicyBase.Policy.XAmzMeta[name].PolicyValue != value {
+			t.Errorf("%s should be [%s] not [%s]", name, value, s3PolicyBase.Policy.XAmzMeta[name].PolicyValue)
+		}
+	}
+
+	var decoded embeddedMeta
+	header := http.Header{"X-Amz-Meta-Tenant": {"tenant2"}, "X-Amz-Meta-Album": {"work"}}
+	if err := DecodeXAmzMetaStruct(header, &decoded); err != nil {
+		t.Fatalf("failed to decode headers: %s", err.Error())
+	}
+
+	if decoded.Tenant != "tenant2" || decoded.Album != "work" {
+		t.Errorf("embedded fields should be decoded: %+v", decoded)
+	}
+}
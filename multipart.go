@@ -0,0 +1,280 @@
+package s3Presign
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 multipart upload limits.
+// See https://docs.aws.amazon.com/AmazonS3/latest/userguide/qfacts.html, for more details
+const MinMultipartPartSize = 5 << 20 // 5 MiB, except the last part
+const MaxMultipartPartSize = 5 << 30 // 5 GiB
+const MaxMultipartParts = 10000
+const MaxMultipartObjectSize = 5 << 40 // 5 TiB
+
+// S3Error error response of the S3 REST API
+type S3Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+	RequestId  string `xml:"RequestId"`
+}
+
+func (s3Error S3Error) Error() string {
+	return fmt.Sprintf("s3 error %d %s: %s", s3Error.StatusCode, s3Error.Code, s3Error.Message)
+}
+
+// MultipartUploader create presigned multipart uploads for files bigger than the POST policy limit (5 GB).
+// CreateMultipartUpload, CompleteMultipartUpload and AbortMultipartUpload are sent with the signer,
+// the parts are uploaded by the client with the presigned UploadPart URLs.
+type MultipartUploader struct {
+	Signer   *RequestSigner
+	Endpoint string       // bucket url, ex: https://<bucket>.s3.<region>.amazonaws.com/ or https://<host>/<bucket>/
+	Client   *http.Client // default http.DefaultClient
+}
+
+// MultipartUploadPlan the created multipart upload with the presigned UploadPart URL of every part,
+// the client upload the parts and send the part numbers and ETag to CompleteMultipartUpload
+type MultipartUploadPlan struct {
+	Key        string          `json:"key"`
+	UploadId   string          `json:"uploadId"`
+	Size       uint64          `json:"size"`
+	PartSize   uint64          `json:"partSize"`
+	Expiration time.Time       `json:"expiration"` // expiration of the presigned URLs
+	Parts      []MultipartPart `json:"parts"`
+}
+
+type MultipartPart struct {
+	PartNumber int    `json:"partNumber"`
+	Offset     uint64 `json:"offset"`
+	Size       uint64 `json:"size"`
+	Url        string `json:"url"` // presigned PUT url
+}
+
+// CompletedPart the uploaded part, ETag is the ETag header of the UploadPart response
+type CompletedPart struct {
+	PartNumber int    `xml:"PartNumber" json:"partNumber"`
+	ETag       string `xml:"ETag" json:"etag"`
+}
+
+// NewMultipartUploader create the multipart uploader for the config bucket, the endpoint is https://<bucket>.s3.<region>.amazonaws.com/
+func NewMultipartUploader(config AwsConfig) *MultipartUploader {
+	return &MultipartUploader{
+		Signer:   NewRequestSigner(config),
+		Endpoint: fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", config.AwsBucket, config.AwsRegion),
+	}
+}
+
+// MultipartUploader create the multipart uploader with the policy credentials and endpoint
+func (base *BaseS3Policy) MultipartUploader() *MultipartUploader {
+	return &MultipartUploader{
+		Signer:   base.RequestSigner(),
+		Endpoint: base.getUrl(),
+	}
+}
+
+// PlanMultipartUpload create the multipart upload (CreateMultipartUpload) and presign the UploadPart URL of every part.
+// partSize 0 use the smallest part size (multiple of 1 MiB) allowed for the size. header is sent to CreateMultipartUpload,
+// ex: Content-Type, Cache-Control or x-amz-meta-*. The upload is aborted if the parts can't be presigned.
+func (uploader *MultipartUploader) PlanMultipartUpload(ctx context.Context, key string, size, partSize uint64, expiresIn time.Duration, header http.Header) (MultipartUploadPlan, error) {
+	if key == "" {
+		return MultipartUploadPlan{}, fmt.Errorf("multipart upload key can't be empty")
+	}
+
+	parts, partSize, err := getMultipartParts(size, partSize)
+	if err != nil {
+		return MultipartUploadPlan{}, err
+	}
+
+	if expiresIn < time.Second || expiresIn > MaxPresignExpiresIn {
+		return MultipartUploadPlan{}, fmt.Errorf("presigned URL expiration must be between 1 second and %s, got %s", MaxPresignExpiresIn, expiresIn)
+	}
+
+	body, err := uploader.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, header, nil)
+	if err != nil {
+		return MultipartUploadPlan{}, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	var result struct {
+		UploadId string `xml:"UploadId"`
+	}
+	if err = xml.Unmarshal(body, &result); err != nil || result.UploadId == "" {
+		return MultipartUploadPlan{}, fmt.Errorf("invalid CreateMultipartUpload response: %s", string(body))
+	}
+
+	plan := MultipartUploadPlan{
+		Key:        key,
+		UploadId:   result.UploadId,
+		Size:       size,
+		PartSize:   partSize,
+		Expiration: uploader.Signer.getClock().Now().Add(expiresIn),
+		Parts:      parts,
+	}
+
+	for idx := range plan.Parts {
+		query := url.Values{
+			"partNumber": {strconv.Itoa(plan.Parts[idx].PartNumber)},
+			"uploadId":   {plan.UploadId},
+		}
+
+		request, err := uploader.newRequest(ctx, http.MethodPut, key, query, nil)
+		if err == nil {
+			plan.Parts[idx].Url, err = uploader.Signer.Presign(request, expiresIn)
+		}
+
+		if err != nil {
+			_ = uploader.AbortMultipartUpload(ctx, plan)
+			return MultipartUploadPlan{}, fmt.Errorf("failed to presign part %d: %w", plan.Parts[idx].PartNumber, err)
+		}
+	}
+
+	return plan, nil
+}
+
+// CompleteMultipartUpload combine the uploaded parts to the object, the parts are sorted by the part number
+func (uploader *MultipartUploader) CompleteMultipartUpload(ctx context.Context, plan MultipartUploadPlan, parts []CompletedPart) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("multipart upload must have at least 1 part")
+	}
+
+	completedParts := append([]CompletedPart(nil), parts...)
+	sort.Slice(completedParts, func(i, j int) bool {
+		return completedParts[i].PartNumber < completedParts[j].PartNumber
+	})
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []CompletedPart `xml:"Part"`
+	}{Parts: completedParts})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/xml"}}
+	if _, err = uploader.do(ctx, http.MethodPost, plan.Key, url.Values{"uploadId": {plan.UploadId}}, header, body); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	return nil
+}
+
+// AbortMultipartUpload abort the multipart upload and delete the uploaded parts
+func (uploader *MultipartUploader) AbortMultipartUpload(ctx context.Context, plan MultipartUploadPlan) error {
+	if _, err := uploader.do(ctx, http.MethodDelete, plan.Key, url.Values{"uploadId": {plan.UploadId}}, nil, nil); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+
+	return nil
+}
+
+// split the size to parts, return the parts without url and the used part size
+func getMultipartParts(size, partSize uint64) ([]MultipartPart, uint64, error) {
+	if size == 0 || size > MaxMultipartObjectSize {
+		return nil, 0, fmt.Errorf("multipart upload size must be between 1 byte and %d bytes, got %d", uint64(MaxMultipartObjectSize), size)
+	}
+
+	if partSize == 0 {
+		// the smallest part size for the max parts, rounded up to 1 MiB
+		partSize = (size + MaxMultipartParts - 1) / MaxMultipartParts
+		partSize = (partSize + 1<<20 - 1) / (1 << 20) * (1 << 20)
+		if partSize < MinMultipartPartSize {
+			partSize = MinMultipartPartSize
+		}
+	}
+
+	if partSize < MinMultipartPartSize || partSize > MaxMultipartPartSize {
+		return nil, 0, fmt.Errorf("multipart part size must be between %d and %d bytes, got %d", MinMultipartPartSize, uint64(MaxMultipartPartSize), partSize)
+	}
+
+	count := (size + partSize - 1) / partSize
+	if count > MaxMultipartParts {
+		return nil, 0, fmt.Errorf("multipart upload has %d parts with part size %d, maximum allowed is %d parts", count, partSize, MaxMultipartParts)
+	}
+
+	parts := make([]MultipartPart, 0, count)
+	for offset := uint64(0); offset < size; offset += partSize {
+		partLength := partSize
+		if size-offset < partSize {
+			partLength = size - offset
+		}
+
+		parts = append(parts, MultipartPart{PartNumber: len(parts) + 1, Offset: offset, Size: partLength})
+	}
+
+	return parts, partSize, nil
+}
+
+// create the request to the object url, the query is encoded like the canonical query
+func (uploader *MultipartUploader) newRequest(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	endpoint, err := url.Parse(uploader.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint [%s]: %w", uploader.Endpoint, err)
+	}
+
+	objectUrl := *endpoint
+	objectUrl.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + key
+	objectUrl.RawPath = ""
+	objectUrl.RawQuery = getCanonicalQuery(&http.Request{URL: &url.URL{RawQuery: query.Encode()}})
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	return http.NewRequestWithContext(ctx, method, objectUrl.String(), bodyReader)
+}
+
+// send the signed request, return S3Error if the response has error
+func (uploader *MultipartUploader) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	request, err := uploader.newRequest(ctx, method, key, query, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		request.Header[name] = append([]string(nil), values...)
+	}
+
+	if err = uploader.Signer.Sign(request, ""); err != nil {
+		return nil, err
+	}
+
+	client := uploader.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// CompleteMultipartUpload can return error with status 200
+	if response.StatusCode >= http.StatusMultipleChoices || bytes.Contains(responseBody, []byte("<Error>")) {
+		s3Error := S3Error{StatusCode: response.StatusCode}
+		if err = xml.Unmarshal(responseBody, &s3Error); err != nil || s3Error.Code == "" {
+			s3Error.Code = http.StatusText(response.StatusCode)
+			s3Error.Message = strings.TrimSpace(string(responseBody))
+		}
+
+		return nil, s3Error
+	}
+
+	return responseBody, nil
+}
@@ -0,0 +1,330 @@
+package s3Presign
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 minimal S3 multipart upload API, every request signature is verified
+// with its own SigV4 implementation, independent of RequestSigner
+type fakeS3 struct {
+	sync.Mutex
+	secretKey string
+	uploadId  int
+	uploads   map[string]map[int][]byte
+	objects   map[string][]byte
+}
+
+func newFakeS3(secretKey string) *httptest.Server {
+	return httptest.NewServer(&fakeS3{
+		secretKey: secretKey,
+		uploads:   map[string]map[int][]byte{},
+		objects:   map[string][]byte{},
+	})
+}
+
+func (fake *fakeS3) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	fake.Lock()
+	defer fake.Unlock()
+
+	body, _ := io.ReadAll(request.Body)
+	if !fake.isValidSignature(request, body) {
+		writeFakeS3Error(writer, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	key := strings.TrimPrefix(request.URL.Path, "/bucket/")
+	query := request.URL.Query()
+	uploadId := query.Get("uploadId")
+	parts, uploadFound := fake.uploads[uploadId]
+
+	switch {
+	case request.Method == http.MethodPost && query.Has("uploads"):
+		fake.uploadId++
+		uploadId = fmt.Sprintf("upload-%d", fake.uploadId)
+		fake.uploads[uploadId] = map[int][]byte{}
+		_, _ = fmt.Fprintf(writer, "<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, uploadId)
+	case !uploadFound:
+		writeFakeS3Error(writer, http.StatusNotFound, "NoSuchUpload")
+	case request.Method == http.MethodPut:
+		var partNumber int
+		_, _ = fmt.Sscan(query.Get("partNumber"), &partNumber)
+		parts[partNumber] = body
+		writer.Header().Set("ETag", getFakeETag(body))
+	case request.Method == http.MethodPost:
+		var complete struct {
+			Parts []CompletedPart `xml:"Part"`
+		}
+		_ = xml.Unmarshal(body, &complete)
+
+		var object []byte
+		for idx, part := range complete.Parts {
+			data, ok := parts[part.PartNumber]
+			if !ok || part.ETag != getFakeETag(data) || (idx > 0 && part.PartNumber <= complete.Parts[idx-1].PartNumber) {
+				// S3 can return the error after status 200
+				writer.WriteHeader(http.StatusOK)
+				_, _ = writer.Write([]byte("<Error><Code>InvalidPart</Code><Message>invalid part</Message></Error>"))
+				return
+			}
+
+			object = append(object, data...)
+		}
+
+		fake.objects[key] = object
+		delete(fake.uploads, uploadId)
+		_, _ = fmt.Fprintf(writer, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+	case request.Method == http.MethodDelete:
+		delete(fake.uploads, uploadId)
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// calculate the signature from the received request, with the Authorization header or the presigned query.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html, for more details
+func (fake *fakeS3) isValidSignature(request *http.Request, body []byte) bool {
+	query := request.URL.Query()
+	credential, signedHeaders, signature := query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders"), query.Get("X-Amz-Signature")
+	amzDate, payloadHash := query.Get("X-Amz-Date"), "UNSIGNED-PAYLOAD"
+	if signature == "" {
+		for _, part := range strings.Split(strings.TrimPrefix(request.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), ", ") {
+			name, value, _ := strings.Cut(part, "=")
+			switch name {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				signature = value
+			}
+		}
+
+		amzDate, payloadHash = request.Header.Get("X-Amz-Date"), request.Header.Get("X-Amz-Content-Sha256")
+		if bodyHash := sha256.Sum256(body); payloadHash != "UNSIGNED-PAYLOAD" && payloadHash != hex.EncodeToString(bodyHash[:]) {
+			return false
+		}
+	}
+
+	// access key/date/region/service/aws4_request
+	scope := strings.SplitN(credential, "/", 2)
+	scopeParts := strings.Split(credential, "/")
+	if len(scope) != 2 || len(scopeParts) != 5 || signature == "" {
+		return false
+	}
+
+	var parameters [][2]string
+	for _, parameter := range strings.Split(request.URL.RawQuery, "&") {
+		name, value, _ := strings.Cut(parameter, "=")
+		name, _ = url.QueryUnescape(name)
+		value, _ = url.QueryUnescape(value)
+		if parameter != "" && name != "X-Amz-Signature" {
+			parameters = append(parameters, [2]string{fakeEncode(name, true), fakeEncode(value, true)})
+		}
+	}
+
+	sort.Slice(parameters, func(i, j int) bool {
+		return parameters[i][0] < parameters[j][0] || (parameters[i][0] == parameters[j][0] && parameters[i][1] < parameters[j][1])
+	})
+
+	canonicalQuery := make([]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		canonicalQuery = append(canonicalQuery, parameter[0]+"="+parameter[1])
+	}
+
+	var canonicalHeaders string
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := strings.Join(request.Header.Values(name), ",")
+		if name == "host" {
+			value = request.Host
+		}
+
+		canonicalHeaders += name + ":" + strings.Join(strings.Fields(value), " ") + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		fakeEncode(request.URL.Path, false),
+		strings.Join(canonicalQuery, "&"),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope[1] + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + fake.secretKey)
+	for _, data := range append(scopeParts[1:], stringToSign) {
+		hash := hmac.New(sha256.New, key)
+		hash.Write([]byte(data))
+		key = hash.Sum(nil)
+	}
+
+	return hex.EncodeToString(key) == signature
+}
+
+// percent encode every byte except the unreserved characters (and "/" of the path)
+func fakeEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, char := range []byte(value) {
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9', strings.IndexByte("-_.~", char) >= 0:
+			encoded.WriteByte(char)
+		case char == '/' && !encodeSlash:
+			encoded.WriteByte(char)
+		default:
+			_, _ = fmt.Fprintf(&encoded, "%%%02X", char)
+		}
+	}
+
+	return encoded.String()
+}
+
+func writeFakeS3Error(writer http.ResponseWriter, statusCode int, code string) {
+	writer.WriteHeader(statusCode)
+	_, _ = fmt.Fprintf(writer, "<Error><Code>%s</Code><Message>%s</Message><RequestId>request-1</RequestId></Error>", code, strings.ToLower(code))
+}
+
+func getFakeETag(data []byte) string {
+	hash := md5.Sum(data)
+	return `"` + hex.EncodeToString(hash[:]) + `"`
+}
+
+func getMultipartUploader(t *testing.T) (*MultipartUploader, *fakeS3) {
+	defaultData := getDefaultData()
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig, WithClock(ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})))
+
+	server := newFakeS3(defaultData.AwsConfig.AwsSecretKey)
+	t.Cleanup(server.Close)
+
+	s3PolicyBase.Endpoint = server.URL + "/bucket/"
+	return s3PolicyBase.MultipartUploader(), server.Config.Handler.(*fakeS3)
+}
+
+func TestMultipartUpload(t *testing.T) {
+	uploader, fake := getMultipartUploader(t)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), (12<<20)/16)
+	plan, err := uploader.PlanMultipartUpload(ctx, "videos/movie 1.mp4", uint64(len(data)), MinMultipartPartSize, time.Hour, http.Header{"Content-Type": {"video/mp4"}})
+	if err != nil {
+		t.Fatalf("failed to plan multipart upload: %s", err.Error())
+	}
+
+	if plan.UploadId != "upload-1" || len(plan.Parts) != 3 || plan.Parts[2].Offset != 10<<20 || plan.Parts[2].Size != 2<<20 {
+		t.Fatalf("invalid multipart upload plan: %+v", plan)
+	}
+
+	// the client upload the parts with the presigned URLs, without credentials
+	var completedParts []CompletedPart
+	for idx := len(plan.Parts) - 1; idx >= 0; idx-- {
+		part := plan.Parts[idx]
+		request, _ := http.NewRequest(http.MethodPut, part.Url, bytes.NewReader(data[part.Offset:part.Offset+part.Size]))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil || response.StatusCode != http.StatusOK {
+			t.Fatalf("failed to upload part %d: %v %v", part.PartNumber, err, response)
+		}
+
+		_ = response.Body.Close()
+		completedParts = append(completedParts, CompletedPart{PartNumber: part.PartNumber, ETag: response.Header.Get("ETag")})
+	}
+
+	if err = uploader.CompleteMultipartUpload(ctx, plan, completedParts); err != nil {
+		t.Fatalf("failed to complete multipart upload: %s", err.Error())
+	}
+
+	if !bytes.Equal(fake.objects["videos/movie 1.mp4"], data) {
+		t.Errorf("uploaded object should be the same as the data")
+	}
+
+	// the upload is completed, so it can't be aborted
+	var s3Error S3Error
+	if err = uploader.AbortMultipartUpload(ctx, plan); !errors.As(err, &s3Error) || s3Error.Code != "NoSuchUpload" || s3Error.StatusCode != http.StatusNotFound {
+		t.Errorf("abort completed upload should return NoSuchUpload, got %v", err)
+	}
+}
+
+func TestMultipartUploadAbort(t *testing.T) {
+	uploader, fake := getMultipartUploader(t)
+	ctx := context.Background()
+
+	plan, err := uploader.PlanMultipartUpload(ctx, "videos/movie.mp4", 6<<20, 0, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("failed to plan multipart upload: %s", err.Error())
+	}
+
+	// error with status 200
+	var s3Error S3Error
+	err = uploader.CompleteMultipartUpload(ctx, plan, []CompletedPart{{PartNumber: 1, ETag: `"invalid"`}})
+	if !errors.As(err, &s3Error) || s3Error.Code != "InvalidPart" {
+		t.Errorf("complete with invalid part should return InvalidPart, got %v", err)
+	}
+
+	if err = uploader.AbortMultipartUpload(ctx, plan); err != nil {
+		t.Fatalf("failed to abort multipart upload: %s", err.Error())
+	}
+
+	if len(fake.uploads) != 0 {
+		t.Errorf("aborted upload should be deleted")
+	}
+
+	uploader.Signer.AwsConfig.AwsSecretKey = "invalid"
+	if _, err = uploader.PlanMultipartUpload(ctx, "videos/movie.mp4", 6<<20, 0, time.Hour, nil); !errors.As(err, &s3Error) || s3Error.Code != "SignatureDoesNotMatch" {
+		t.Errorf("invalid signature should return SignatureDoesNotMatch, got %v", err)
+	}
+
+	// the regional virtual-hosted endpoint
+	if endpoint := NewMultipartUploader(getDefaultData().AwsConfig).Endpoint; endpoint != "https://sigv4examplebucket.s3.us-east-1.amazonaws.com/" {
+		t.Errorf("endpoint should use the bucket and region, got %s", endpoint)
+	}
+}
+
+func TestGetMultipartParts(t *testing.T) {
+	testCases := []struct {
+		size, partSize, expectedPartSize uint64
+		expectedParts                    int
+	}{
+		{size: 1, expectedPartSize: MinMultipartPartSize, expectedParts: 1},
+		{size: 100 << 30, expectedPartSize: 11 << 20, expectedParts: 9310},
+		{size: MaxMultipartObjectSize, expectedPartSize: 525 << 20, expectedParts: 9987},
+		{size: 12 << 20, partSize: 6 << 20, expectedPartSize: 6 << 20, expectedParts: 2},
+	}
+
+	for _, testCase := range testCases {
+		parts, partSize, err := getMultipartParts(testCase.size, testCase.partSize)
+		if err != nil || partSize != testCase.expectedPartSize || len(parts) != testCase.expectedParts {
+			t.Errorf("size %d should have %d parts of %d bytes, got %d parts of %d bytes: %v",
+				testCase.size, testCase.expectedParts, testCase.expectedPartSize, len(parts), partSize, err)
+			continue
+		}
+
+		lastPart := parts[len(parts)-1]
+		if lastPart.Offset+lastPart.Size != testCase.size {
+			t.Errorf("parts of size %d should end at the size, got %+v", testCase.size, lastPart)
+		}
+	}
+
+	invalidSizes := [][2]uint64{{0, 0}, {MaxMultipartObjectSize + 1, 0}, {10 << 20, 1 << 20}, {10 << 30, 6 << 30}, {100 << 30, MinMultipartPartSize}}
+	for _, size := range invalidSizes {
+		if _, _, err := getMultipartParts(size[0], size[1]); err == nil {
+			t.Errorf("size %d with part size %d should return error", size[0], size[1])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package s3Presign
+
+import (
+	"time"
+)
+
+// Option configure BaseS3Policy created with NewS3Policy
+type Option func(base *BaseS3Policy)
+
+// WithService set the service used for creating signature, default "s3"
+func WithService(service string) Option {
+	return func(base *BaseS3Policy) {
+		base.AwsService = service
+	}
+}
+
+// WithExpiry set the policy expiration relative to the signing date, default 10 minutes
+func WithExpiry(expiresIn time.Duration) Option {
+	return func(base *BaseS3Policy) {
+		base.ExpiresIn = expiresIn
+	}
+}
+
+// WithEndpoint set the form url, see BaseS3Policy.Endpoint
+func WithEndpoint(endpoint string) Option {
+	return func(base *BaseS3Policy) {
+		base.Endpoint = endpoint
+	}
+}
+
+// WithClock use the clock for the signing date
+func WithClock(clock Clock) Option {
+	return func(base *BaseS3Policy) {
+		base.Clock = clock
+	}
+}
+
+// WithTTLBounds set the minimum and maximum time between the signing date and the policy expiration
+func WithTTLBounds(minTTL, maxTTL time.Duration) Option {
+	return func(base *BaseS3Policy) {
+		base.SetTTLBounds(minTTL, maxTTL)
+	}
+}
+
+// Clone deep copy the policy, use it to derive per-request policy from a shared template.
+// The template must not be changed while it's cloned by other goroutines,
+// AwsConfig.Guardrails and Clock are shared with the clone.
+func (base *BaseS3Policy) Clone() *BaseS3Policy {
+	clone := *base
+	if base.Policy != nil {
+		clone.Policy = base.Policy.Clone()
+	}
+
+	if base.LintRules != nil {
+		clone.LintRules = append([]LintRule(nil), base.LintRules...)
+	}
+
+	return &clone
+}
+
+// Clone deep copy the policy conditions
+func (policy *Policy) Clone() *Policy {
+	clone := *policy
+	clone.XAmzMeta = clonePolicyConditions(policy.XAmzMeta)
+	clone.XAmz = clonePolicyConditions(policy.XAmz)
+	if policy.Conditions != nil {
+		clone.Conditions = append([]Condition(nil), policy.Conditions...)
+	}
+
+	if policy.AllowedContentTypes != nil {
+		clone.AllowedContentTypes = append([]string(nil), policy.AllowedContentTypes...)
+	}
+
+	return &clone
+}
+
+func clonePolicyConditions(policyConditions map[string]PolicyConditions) map[string]PolicyConditions {
+	if policyConditions == nil {
+		return nil
+	}
+
+	clone := make(map[string]PolicyConditions, len(policyConditions))
+	for key, value := range policyConditions {
+		clone[key] = value
+	}
+
+	return clone
+}
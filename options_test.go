@@ -0,0 +1,108 @@
+package s3Presign
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOptions(t *testing.T) {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig,
+		WithClock(clock),
+		WithService("s3-custom"),
+		WithExpiry(time.Hour),
+		WithEndpoint("http://localhost:9000/"+AwsBucket+"/"),
+	)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if formsData.Url != "http://localhost:9000/"+AwsBucket+"/" {
+		t.Errorf("url should use the endpoint, got [%s]", formsData.Url)
+	}
+
+	if !formsData.Expiration.Equal(defaultData.DateCreated.Add(time.Hour)) {
+		t.Errorf("expiration should be 1 hour after signing date, got [%s]", formsData.Expiration)
+	}
+
+	if !hasFormValue(formsData, "x-amz-credential", AWSAccessKeyId+"/20151229/us-east-1/s3-custom/aws4_request") {
+		t.Errorf("x-amz-credential should use the service")
+	}
+}
+
+func TestClone(t *testing.T) {
+	defaultData := getDefaultData()
+
+	template := NewS3Policy(defaultData.AwsConfig)
+	template.SetContentLengthPolicy(1, 1048576)
+	template.SetXAmzMeta("tenant", ConditionMatchingExactMatch, "acme")
+	template.AddCondition("$key", ConditionMatchingStartWith, "avatar/")
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+
+			key := fmt.Sprintf("avatar/%d.png", i)
+			s3Policy := template.Clone()
+			s3Policy.SetKeyPolicy(ConditionMatchingExactMatch, key)
+			s3Policy.SetXAmzMeta("uploader", ConditionMatchingExactMatch, fmt.Sprint(i))
+			s3Policy.AddCondition("x-amz-meta-uploader", ConditionMatchingStartWith, "")
+
+			_, _, formsData, err := s3Policy.GeneratePolicyWithError()
+			if err != nil {
+				t.Errorf("failed to generate policy: %s", err.Error())
+				return
+			}
+
+			if !hasFormValue(formsData, "key", key) || !hasFormValue(formsData, "x-amz-meta-uploader", fmt.Sprint(i)) {
+				t.Errorf("clone %d should have its own key and metadata", i)
+			}
+		}(i)
+	}
+
+	waitGroup.Wait()
+
+	if template.Policy.Key.ConditionUsed != "" || len(template.Policy.XAmzMeta) != 1 || len(template.Policy.Conditions) != 1 {
+		t.Errorf("template should not be changed by the clones")
+	}
+}
+
+func TestCloneGeneratedTemplate(t *testing.T) {
+	defaultData := getDefaultData()
+	defaultData.AwsConfig.AwsBucket = "one"
+
+	template := NewS3Policy(defaultData.AwsConfig)
+	template.SetKeyPolicy(ConditionMatchingStartWith, "avatar/")
+	before, _ := template.State()
+	if _, _, _, err := template.GeneratePolicyWithError(); err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	// the generation doesn't change the template
+	if after, _ := template.State(); !reflect.DeepEqual(before, after) {
+		t.Errorf("generated template should not be changed: %+v", after)
+	}
+
+	s3Policy := template.Clone()
+	s3Policy.AwsConfig.AwsBucket = "two"
+	_, _, formsData, err := s3Policy.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if formsData.Url != "https://two.s3.amazonaws.com/" || !hasFormValue(formsData, "bucket", "two") {
+		t.Errorf("clone should post to bucket two: %s %+v", formsData.Url, formsData.FormData)
+	}
+}
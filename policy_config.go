@@ -1,174 +1,302 @@
 package s3Presign
 
-import "sync"
-
-var validInputForm = map[string]bool{
-	// must have in valid input form
-	"key":              true,
-	"bucket":           true,
-	"x-amz-date":       true,
-	"x-amz-credential": true,
-	"x-amz-algorithm":  true,
-	"policy":           true,
-	"x-amz-signature":  true,
-
-	// optional in valid input form
-	"acl":                                             true,
-	"Cache-Control":                                   true,
-	"Content-Type":                                    true,
-	"Content-Disposition":                             true,
-	"Content-Encoding":                                true,
-	"Expires":                                         true,
-	"success_action_redirect":                         true,
-	"success_action_status":                           true,
-	"x-amz-storage-class":                             true,
-	"x-amz-meta-":                                     true,
-	"x-amz-security-token":                            true,
-	"x-amz-website-redirect-location":                 true,
-	"x-amz-checksum-algorithm":                        true,
-	"x-amz-checksum-crc32":                            true,
-	"x-amz-checksum-crc32c":                           true,
-	"x-amz-checksum-sha1":                             true,
-	"x-amz-checksum-sha256":                           true,
-	"x-amz-server-side-encryption":                    true,
-	"x-amz-server-side-encryption-aws-kms-key-id":     true,
-	"x-amz-server-side-encryption-context":            true,
-	"x-amz-server-side-encryption-bucket-key-enabled": true,
-	"x-amz-server-side-encryption-customer-algorithm": true,
-	"x-amz-server-side-encryption-customer-key":       true,
-	"x-amz-server-side-encryption-customer-key-MD5":   true,
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PolicyField describe a field that can be used in the policy conditions.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html#sigv4-PolicyConditions
+type PolicyField struct {
+	// Name of the form field, name ending with "*" is a prefix for any field starting with it (ex: "x-amz-meta-*")
+	Name string
+
+	// Condition matching that can be used for the field
+	Conditions ConditionMatching
+
+	// FormField the value must be sent as form field, otherwise it's only used in the policy conditions
+	FormField bool
+
+	// Required the policy must have condition for the field
+	Required bool
+
+	// Validate the value used with "eq" condition, optional
+	Validate func(value string) error
 }
 
-type PolicyConfig struct {
-	PolicyData *Policy
+type policyFieldRegistry struct {
+	sync.RWMutex
+	fields map[string]PolicyField
 }
 
-var policyConfigInstance *PolicyConfig
-var policyConfigOnce sync.Once
+var policyFields = &policyFieldRegistry{fields: map[string]PolicyField{}}
 
-func getPolicyConfig() *Policy {
-	policyConfigOnce.Do(func() {
-		conditionAcl := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
-		}
+func init() {
+	exactMatch := ConditionMatching{ExactMatch: true}
+	exactOrStartWith := ConditionMatching{ExactMatch: true, StartWith: true}
 
-		conditionBucket := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
-		}
+	defaultFields := []PolicyField{
+		// must have in valid input form
+		{Name: "key", Conditions: exactOrStartWith, FormField: true, Required: true},
+		{Name: "bucket", Conditions: exactOrStartWith, FormField: true, Required: true},
+		{Name: "x-amz-algorithm", Conditions: exactMatch, FormField: true, Required: true, Validate: validateOneOf(AmzAlgorithm)},
+		{Name: "x-amz-credential", Conditions: exactMatch, FormField: true, Required: true},
+		{Name: "x-amz-date", Conditions: exactMatch, FormField: true, Required: true, Validate: validateTime(AmzDateFormat)},
+
+		// policy and signature are added on generation, they are not part of the policy conditions
+		{Name: "policy", FormField: true},
+		{Name: "x-amz-signature", FormField: true},
+
+		// optional in valid input form
+		{Name: "acl", Conditions: exactOrStartWith, FormField: true, Validate: validateOneOf(
+			"private", "public-read", "public-read-write", "aws-exec-read", "authenticated-read",
+			"bucket-owner-read", "bucket-owner-full-control", "log-delivery-write")},
+		{Name: ConditionSpecifyingRange, Conditions: ConditionMatching{SpecifyingRange: true}},
+		{Name: "Cache-Control", Conditions: exactOrStartWith, FormField: true},
+		{Name: "Content-Type", Conditions: exactOrStartWith, FormField: true},
+		{Name: "Content-Disposition", Conditions: exactOrStartWith, FormField: true},
+		{Name: "Content-Encoding", Conditions: exactOrStartWith, FormField: true},
+		{Name: "Content-Language", Conditions: exactOrStartWith, FormField: true},
+		{Name: "Expires", Conditions: exactOrStartWith, FormField: true, Validate: validateHttpTime},
+		{Name: "success_action_redirect", Conditions: exactOrStartWith, FormField: true},
+		{Name: "success_action_status", Conditions: exactOrStartWith, FormField: true, Validate: validateOneOf("200", "201", "204")},
+		{Name: "tagging", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-storage-class", Conditions: exactMatch, FormField: true, Validate: validateOneOf(
+			"STANDARD", "REDUCED_REDUNDANCY", "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING",
+			"GLACIER", "DEEP_ARCHIVE", "GLACIER_IR", "EXPRESS_ONEZONE")},
+		{Name: XAmzMetaKey + "*", Conditions: exactOrStartWith, FormField: true},
+		{Name: "x-amz-security-token", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-website-redirect-location", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-checksum-algorithm", Conditions: exactMatch, FormField: true, Validate: validateOneOf(
+			"CRC32", "CRC32C", "SHA1", "SHA256")},
+		{Name: "x-amz-checksum-crc32", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-checksum-crc32c", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-checksum-sha1", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-checksum-sha256", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-server-side-encryption", Conditions: exactMatch, FormField: true, Validate: validateOneOf(
+			"AES256", "aws:kms", "aws:kms:dsse")},
+		{Name: "x-amz-server-side-encryption-aws-kms-key-id", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-server-side-encryption-context", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-server-side-encryption-bucket-key-enabled", Conditions: exactMatch, FormField: true, Validate: validateOneOf(
+			"true", "false")},
+		{Name: "x-amz-server-side-encryption-customer-algorithm", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-server-side-encryption-customer-key", Conditions: exactMatch, FormField: true},
+		{Name: "x-amz-server-side-encryption-customer-key-MD5", Conditions: exactMatch, FormField: true},
 
-		conditionContentLength := PolicyConditions{
-			Conditions: ConditionMatching{
-				SpecifyingRange: true,
-			},
+		// other x-amz-* fields are only used in policy conditions
+		{Name: XAmzKey + "*", Conditions: exactMatch},
+
+		// fields starting with x-ignore- are ignored by S3, it can be used to send extra data with the form
+		{Name: "x-ignore-*", Conditions: exactOrStartWith},
+	}
+
+	for _, field := range defaultFields {
+		if err := RegisterPolicyField(field); err != nil {
+			panic(err.Error())
 		}
+	}
+}
 
-		conditionCacheControl := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
+// RegisterPolicyField add or replace a field in the policy field registry,
+// use this to support new fields added by AWS (ex: RegisterPolicyField(PolicyField{Name: "x-amz-new-field", ...})).
+func RegisterPolicyField(field PolicyField) error {
+	if field.Name == "" || field.Name == "*" {
+		return fmt.Errorf("policy field name can't be empty")
+	}
+
+	policyFields.Lock()
+	defer policyFields.Unlock()
+
+	policyFields.fields[field.Name] = field
+	return nil
+}
+
+// remove the field from the policy field registry
+func unregisterPolicyField(name string) {
+	policyFields.Lock()
+	defer policyFields.Unlock()
+
+	delete(policyFields.fields, name)
+}
+
+// GetPolicyField get the field from the policy field registry,
+// the field with the same name is used first, otherwise the field with the longest prefix.
+func GetPolicyField(name string) (PolicyField, bool) {
+	policyFields.RLock()
+	defer policyFields.RUnlock()
+
+	if field, ok := policyFields.fields[name]; ok {
+		return field, true
+	}
+
+	var prefixField PolicyField
+	var found bool
+	for fieldName, field := range policyFields.fields {
+		prefix := strings.TrimSuffix(fieldName, "*")
+		if prefix == fieldName || !strings.HasPrefix(name, prefix) {
+			continue
 		}
 
-		conditionContentType := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
+		if !found || len(prefix) > len(strings.TrimSuffix(prefixField.Name, "*")) {
+			prefixField = field
+			found = true
 		}
+	}
+
+	return prefixField, found
+}
 
-		conditionContentDisposition := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
+// PolicyFields get all fields in the policy field registry, sorted by name.
+func PolicyFields() []PolicyField {
+	policyFields.RLock()
+	defer policyFields.RUnlock()
+
+	fields := make([]PolicyField, 0, len(policyFields.fields))
+	for _, field := range policyFields.fields {
+		fields = append(fields, field)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+
+	return fields
+}
+
+type policyField struct {
+	name      string
+	condition *PolicyConditions
+}
+
+// get all fields modeled in Policy with the form field name, in the same order as Policy
+func (policy *Policy) getFields() []policyField {
+	return []policyField{
+		{name: "acl", condition: &policy.Acl},
+		{name: "bucket", condition: &policy.Bucket},
+		{name: ConditionSpecifyingRange, condition: &policy.ContentLengthRange},
+		{name: "Cache-Control", condition: &policy.CacheControl},
+		{name: "Content-Type", condition: &policy.ContentType},
+		{name: "Content-Disposition", condition: &policy.ContentDisposition},
+		{name: "Content-Encoding", condition: &policy.ContentEncoding},
+		{name: "Content-Language", condition: &policy.ContentLanguage},
+		{name: "Expires", condition: &policy.Expires},
+		{name: "key", condition: &policy.Key},
+		{name: "success_action_redirect", condition: &policy.SuccessActionRedirect},
+		{name: "success_action_status", condition: &policy.SuccessActionStatus},
+		{name: "x-amz-algorithm", condition: &policy.XAmzAlgorithm},
+		{name: "x-amz-credential", condition: &policy.XAmzCredential},
+		{name: "x-amz-date", condition: &policy.XAmzDate},
+		{name: "x-amz-security-token", condition: &policy.XAmzSecurityToken},
+	}
+}
+
+// check if the policy have condition for the field
+func (policy *Policy) hasCondition(name string) bool {
+	for _, field := range policy.getFields() {
+		if field.name == name && field.condition.ConditionUsed != "" {
+			return true
 		}
+	}
+
+	if _, ok := policy.XAmzMeta[name]; ok {
+		return true
+	}
+
+	if _, ok := policy.XAmz[name]; ok {
+		return true
+	}
 
-		conditionContentEncoding := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
+	for _, condition := range policy.Conditions {
+		if condition.Field == name {
+			return true
 		}
+	}
 
-		conditionExpires := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
+	return false
+}
+
+// validate the policy conditions with the policy field registry,
+// the required fields set on generation (bucket and signing fields) are only checked if generated is true
+func (policy *Policy) validateFields(generated bool) error {
+	validateValue := func(name string, condition PolicyConditions) error {
+		if condition.ConditionUsed != ConditionMatchingExactMatch {
+			return nil
 		}
 
-		conditionKey := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
+		field, ok := GetPolicyField(name)
+		if !ok || field.Validate == nil {
+			return nil
 		}
 
-		conditionSuccessActionRedirect := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-				StartWith:  true,
-			},
+		if err := field.Validate(condition.PolicyValue); err != nil {
+			return fmt.Errorf("invalid value for field [%s]: %w", name, err)
 		}
 
-		conditionSuccessActionStatus := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-			},
+		return nil
+	}
+
+	for _, field := range policy.getFields() {
+		if err := validateValue(field.name, *field.condition); err != nil {
+			return err
 		}
+	}
 
-		conditionAmzAlgo := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-			},
+	for _, xAmz := range []map[string]PolicyConditions{policy.XAmzMeta, policy.XAmz} {
+		for name, condition := range xAmz {
+			if err := validateValue(name, condition); err != nil {
+				return err
+			}
 		}
+	}
 
-		conditionAmzCredential := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-			},
+	for _, condition := range policy.Conditions {
+		err := validateValue(condition.Field, PolicyConditions{ConditionUsed: condition.ConditionUsed, PolicyValue: condition.PolicyValue})
+		if err != nil {
+			return err
 		}
+	}
 
-		conditionAmzDate := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-			},
+	for _, field := range PolicyFields() {
+		if !generated && (field.Name == "bucket" || isSigningCondition(field.Name)) {
+			continue
 		}
 
-		conditionAmzSecurityToken := PolicyConditions{
-			Conditions: ConditionMatching{
-				ExactMatch: true,
-			},
+		if field.Required && !policy.hasCondition(field.Name) {
+			return fmt.Errorf("policy must have condition for field [%s]", field.Name)
 		}
+	}
 
-		s3Policy := Policy{
-			Acl:                   conditionAcl,
-			Bucket:                conditionBucket,
-			ContentLengthRange:    conditionContentLength,
-			CacheControl:          conditionCacheControl,
-			ContentType:           conditionContentType,
-			ContentDisposition:    conditionContentDisposition,
-			ContentEncoding:       conditionContentEncoding,
-			Expires:               conditionExpires,
-			Key:                   conditionKey,
-			SuccessActionRedirect: conditionSuccessActionRedirect,
-			SuccessActionStatus:   conditionSuccessActionStatus,
-			XAmzAlgorithm:         conditionAmzAlgo,
-			XAmzCredential:        conditionAmzCredential,
-			XAmzDate:              conditionAmzDate,
-			XAmzSecurityToken:     conditionAmzSecurityToken,
+	return nil
+}
+
+func validateOneOf(values ...string) func(value string) error {
+	return func(value string) error {
+		if !inStrings(values, value) {
+			return fmt.Errorf("value must be one of %v", values)
 		}
 
-		policyConfigInstance = &PolicyConfig{
-			PolicyData: &s3Policy,
+		return nil
+	}
+}
+
+func validateTime(layout string) func(value string) error {
+	return func(value string) error {
+		if _, err := time.Parse(layout, value); err != nil {
+			return fmt.Errorf("value must be in format [%s]", layout)
 		}
-	})
 
-	return policyConfigInstance.PolicyData
+		return nil
+	}
+}
+
+func validateHttpTime(value string) error {
+	if _, err := http.ParseTime(value); err != nil {
+		return fmt.Errorf("value must be HTTP-date, ex: [%s]", ExpiredHeaderFormat)
+	}
+
+	return nil
 }
@@ -0,0 +1,106 @@
+package s3Presign
+
+import (
+	"testing"
+)
+
+func TestPolicyFieldRegistry(t *testing.T) {
+	testField := map[string]string{
+		"key":                          "key",
+		"x-amz-meta-uuid":              XAmzMetaKey + "*",
+		"x-amz-server-side-encryption": "x-amz-server-side-encryption",
+		"x-amz-unknown":                XAmzKey + "*",
+		"x-ignore-tracking":            "x-ignore-*",
+	}
+
+	for name, testResult := range testField {
+		field, ok := GetPolicyField(name)
+		if !ok || field.Name != testResult {
+			t.Errorf("field [%s] should use registry field [%s] not [%s]", name, testResult, field.Name)
+		}
+	}
+
+	if _, ok := GetPolicyField("unknown-field"); ok {
+		t.Errorf("field [unknown-field] should not be registered")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("condition for unregistered field should panic")
+			}
+		}()
+
+		NewS3Policy(getDefaultData().AwsConfig).AddCondition("x-new-field", ConditionMatchingExactMatch, "value")
+	}()
+
+	err := RegisterPolicyField(PolicyField{
+		Name:       "x-new-field",
+		Conditions: ConditionMatching{ExactMatch: true},
+		FormField:  true,
+		Validate:   validateOneOf("value"),
+	})
+	if err != nil {
+		t.Fatalf("failed to register field: %s", err.Error())
+	}
+
+	t.Cleanup(func() {
+		unregisterPolicyField("x-new-field")
+	})
+
+	s3PolicyBase := NewS3Policy(getDefaultData().AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, getDefaultData().Key)
+	s3PolicyBase.AddCondition("x-new-field", ConditionMatchingExactMatch, "value")
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if !hasFormValue(formsData, "x-new-field", "value") {
+		t.Errorf("registered form field [x-new-field] should be in form data")
+	}
+}
+
+func TestPolicyFieldValidate(t *testing.T) {
+	defaultData := getDefaultData()
+
+	testPolicy := map[string]func(base *BaseS3Policy){
+		"missing key": func(base *BaseS3Policy) {},
+		"invalid acl": func(base *BaseS3Policy) {
+			base.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+			base.SetAclPolicy(ConditionMatchingExactMatch, "everyone")
+		},
+		"invalid success action status": func(base *BaseS3Policy) {
+			base.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+			base.AddCondition("success_action_status", ConditionMatchingExactMatch, "404")
+		},
+	}
+
+	for name, setPolicy := range testPolicy {
+		s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+		setPolicy(s3PolicyBase)
+		if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err == nil {
+			t.Errorf("policy with %s should return error", name)
+		}
+
+		if err := s3PolicyBase.Policy.Validate(); err == nil {
+			t.Errorf("validate policy with %s should return error", name)
+		}
+	}
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	if err := s3PolicyBase.Policy.Validate(); err != nil {
+		t.Errorf("valid policy should not return error: %s", err.Error())
+	}
+}
+
+func hasFormValue(forms Forms, name, value string) bool {
+	for _, formData := range forms.FormData {
+		if formData.FormName == name {
+			return formData.FormValue == value
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,184 @@
+package s3Presign
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// get all policy conditions in order: fields modeled in Policy, x-amz-meta-* and x-amz-* (sorted by name),
+// then conditions added with AddCondition and AddRange.
+func (policy *Policy) getConditions() []Condition {
+	conditions := make([]Condition, 0, 16+len(policy.XAmzMeta)+len(policy.XAmz)+len(policy.Conditions))
+	for _, field := range policy.getFields() {
+		if field.condition.ConditionUsed == "" {
+			continue
+		}
+
+		conditions = append(conditions, newCondition(field.name, *field.condition))
+	}
+
+	for _, xAmz := range []map[string]PolicyConditions{policy.XAmzMeta, policy.XAmz} {
+		names := make([]string, 0, len(xAmz))
+		for name := range xAmz {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			conditions = append(conditions, newCondition(name, xAmz[name]))
+		}
+	}
+
+	return append(conditions, policy.Conditions...)
+}
+
+func newCondition(field string, policyCondition PolicyConditions) Condition {
+	return Condition{
+		Field:            field,
+		ConditionUsed:    policyCondition.ConditionUsed,
+		PolicyValue:      policyCondition.PolicyValue,
+		PolicyStartRange: policyCondition.PolicyStartRange,
+		PolicyStopRange:  policyCondition.PolicyStopRange,
+	}
+}
+
+// get form values of the conditions, only for fields registered as form field.
+// the same form field is only added once, "eq" value will replace "starts-with" value.
+func getFormValues(conditions []Condition) []FormData {
+	formValues := make([]FormData, 0, len(conditions)+2) // +2 for policy and signature
+	for _, condition := range conditions {
+		if condition.ConditionUsed == ConditionSpecifyingRange {
+			continue
+		}
+
+		policyField, ok := GetPolicyField(condition.Field)
+		if !ok || !policyField.FormField {
+			continue
+		}
+
+		formValues = setFormValue(formValues, FormData{FormName: condition.Field, FormValue: condition.PolicyValue},
+			condition.ConditionUsed == ConditionMatchingExactMatch)
+	}
+
+	return formValues
+}
+
+func setFormValue(formValues []FormData, formValue FormData, replace bool) []FormData {
+	for idx, value := range formValues {
+		if value.FormName != formValue.FormName {
+			continue
+		}
+
+		if replace {
+			formValues[idx] = formValue
+		}
+
+		return formValues
+	}
+
+	return append(formValues, formValue)
+}
+
+// encode the policy document as JSON, the same output as encoding/json without building intermediate values:
+// {"expiration":"...","conditions":[{"field":"value"},["starts-with","$field","value"],["content-length-range",min,max]]}
+func encodePolicyDocument(expiration string, conditions []Condition) []byte {
+	buffer := bytes.NewBuffer(make([]byte, 0, 64+len(conditions)*64))
+	buffer.WriteString(`{"expiration":`)
+	writeJSONString(buffer, expiration)
+	buffer.WriteString(`,"conditions":[`)
+	for idx, condition := range conditions {
+		if idx > 0 {
+			buffer.WriteByte(',')
+		}
+
+		switch condition.ConditionUsed {
+		case ConditionMatchingExactMatch:
+			buffer.WriteByte('{')
+			writeJSONString(buffer, condition.Field)
+			buffer.WriteByte(':')
+			writeJSONString(buffer, condition.PolicyValue)
+			buffer.WriteByte('}')
+		case ConditionMatchingStartWith:
+			buffer.WriteString(`["starts-with",`)
+			writeJSONString(buffer, "$"+condition.Field)
+			buffer.WriteByte(',')
+			writeJSONString(buffer, condition.PolicyValue)
+			buffer.WriteByte(']')
+		case ConditionSpecifyingRange:
+			buffer.WriteString(`["content-length-range",`)
+			buffer.WriteString(strconv.FormatUint(condition.PolicyStartRange, 10))
+			buffer.WriteByte(',')
+			buffer.WriteString(strconv.FormatUint(condition.PolicyStopRange, 10))
+			buffer.WriteByte(']')
+		}
+	}
+
+	buffer.WriteString("]}")
+	return buffer.Bytes()
+}
+
+// write value as JSON string, escaped the same way as encoding/json (including HTML characters)
+func writeJSONString(buffer *bytes.Buffer, value string) {
+	const hex = "0123456789abcdef"
+
+	buffer.WriteByte('"')
+	start := 0
+	for i := 0; i < len(value); {
+		if char := value[i]; char < utf8.RuneSelf {
+			if char >= 0x20 && char != '"' && char != '\\' && char != '<' && char != '>' && char != '&' {
+				i++
+				continue
+			}
+
+			buffer.WriteString(value[start:i])
+			switch char {
+			case '"', '\\':
+				buffer.WriteByte('\\')
+				buffer.WriteByte(char)
+			case '\n':
+				buffer.WriteString(`\n`)
+			case '\r':
+				buffer.WriteString(`\r`)
+			case '\t':
+				buffer.WriteString(`\t`)
+			case '\b':
+				buffer.WriteString(`\b`)
+			case '\f':
+				buffer.WriteString(`\f`)
+			default:
+				buffer.WriteString(`\u00`)
+				buffer.WriteByte(hex[char>>4])
+				buffer.WriteByte(hex[char&0xf])
+			}
+
+			i++
+			start = i
+			continue
+		}
+
+		char, size := utf8.DecodeRuneInString(value[i:])
+		if char == utf8.RuneError && size == 1 {
+			buffer.WriteString(value[start:i])
+			buffer.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+
+		if char == '\u2028' || char == '\u2029' {
+			buffer.WriteString(value[start:i])
+			buffer.WriteString(`\u202`)
+			buffer.WriteByte(hex[char&0xf])
+			i += size
+			start = i
+			continue
+		}
+
+		i += size
+	}
+
+	buffer.WriteString(value[start:])
+	buffer.WriteByte('"')
+}
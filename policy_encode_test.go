@@ -0,0 +1,227 @@
+package s3Presign
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+)
+
+func getTestPolicy() *BaseS3Policy {
+	defaultData := getDefaultData()
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.Date = defaultData.DateCreated
+	s3PolicyBase.SetExpirationDate(defaultData.TimeExpired)
+
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, defaultData.Acl)
+	s3PolicyBase.SetContentLengthPolicy(defaultData.StartRange, defaultData.StopRange)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetSuccessActionRedirectPolicy(ConditionMatchingExactMatch, defaultData.SuccessActionRedirect+"?a=1&b=<2>")
+	s3PolicyBase.SetSuccessActionStatusPolicy(ConditionMatchingStartWith, defaultData.SuccessActionStatus)
+	s3PolicyBase.SetXAmzSecurityTokenPolicy(ConditionMatchingExactMatch, defaultData.UserToken, defaultData.ProductToken)
+	s3PolicyBase.SetCacheControlPolicy(ConditionMatchingStartWith, defaultData.CacheControl)
+	s3PolicyBase.SetContentTypePolicy(ConditionMatchingExactMatch, defaultData.ContentType)
+	s3PolicyBase.SetContentDispositionPolicy(ConditionMatchingExactMatch, `attachment; filename="test\".jpeg"`)
+	s3PolicyBase.SetContentEncodingPolicy(ConditionMatchingExactMatch, defaultData.ContentEncoding)
+	s3PolicyBase.SetExpiresPolicy(defaultData.TimeExpired)
+	s3PolicyBase.SetXAmzMeta("uuid", ConditionMatchingExactMatch, defaultData.Uuid)
+	s3PolicyBase.SetXAmzMeta("tag", ConditionMatchingStartWith, "line\nbreak\ttab")
+	s3PolicyBase.SetXAmzMeta("title", ConditionMatchingExactMatch, "café")
+	s3PolicyBase.SetXAmz("x-amz-server-side-encryption", ConditionMatchingExactMatch, defaultData.SSE)
+	s3PolicyBase.AddCondition("$x-ignore-tracking", ConditionMatchingStartWith, "\u2028\x01")
+	s3PolicyBase.SetXAmzMetaEncoding(true)
+	return s3PolicyBase
+}
+
+// legacy encoding of the policy document, using JSON round-trip of Policy,
+// used as reference for the output of encodePolicyDocument
+func legacyEncodePolicyDocument(policy Policy, expiration string) ([]byte, []FormData) {
+	var policyConditions []interface{}
+	var formValues []FormData
+	addCondition := func(elementName string, policyCondition PolicyConditions) {
+		var isFormData bool
+		if policyField, ok := GetPolicyField(elementName); ok {
+			isFormData = policyField.FormField
+		}
+
+		switch policyCondition.ConditionUsed {
+		case ConditionMatchingExactMatch:
+			policyConditions = append(policyConditions, ExactMatch{elementName: policyCondition.PolicyValue})
+		case ConditionMatchingStartWith:
+			policyConditions = append(policyConditions, StartWith{ConditionMatchingStartWith, "$" + elementName, policyCondition.PolicyValue})
+		case ConditionSpecifyingRange:
+			policyConditions = append(policyConditions, SpecifyingRange{ConditionSpecifyingRange, policyCondition.PolicyStartRange, policyCondition.PolicyStopRange})
+			return
+		default:
+			return
+		}
+
+		if isFormData {
+			formValues = append(formValues, FormData{FormName: elementName, FormValue: policyCondition.PolicyValue})
+		}
+	}
+
+	var policyData map[string]interface{}
+	policyDataMarshal, _ := json.Marshal(policy)
+	_ = json.Unmarshal(policyDataMarshal, &policyData)
+	for elementName, value := range policyData {
+		if value == nil {
+			continue
+		}
+
+		conditionMarshal, _ := json.Marshal(value)
+		if elementName == "x_amz_meta" || elementName == "x_amz" {
+			var policyConditionData map[string]PolicyConditions
+			_ = json.Unmarshal(conditionMarshal, &policyConditionData)
+			for name, policyCondition := range policyConditionData {
+				addCondition(name, policyCondition)
+			}
+
+			continue
+		}
+
+		var policyCondition PolicyConditions
+		_ = json.Unmarshal(conditionMarshal, &policyCondition)
+		addCondition(elementName, policyCondition)
+	}
+
+	for _, condition := range policy.Conditions {
+		addCondition(condition.Field, PolicyConditions{
+			ConditionUsed:    condition.ConditionUsed,
+			PolicyValue:      condition.PolicyValue,
+			PolicyStartRange: condition.PolicyStartRange,
+			PolicyStopRange:  condition.PolicyStopRange,
+		})
+	}
+
+	newPolicy := map[string]interface{}{
+		"expiration": expiration,
+		"conditions": policyConditions,
+	}
+
+	newPolicyMarshal, _ := json.Marshal(newPolicy)
+	return newPolicyMarshal, formValues
+}
+
+// get the policy document conditions sorted, so documents can be compared regardless of the conditions order
+func getSortedConditions(t testing.TB, policyDocument []byte) (expiration string, conditions []string) {
+	var document struct {
+		Expiration string            `json:"expiration"`
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+
+	if err := json.Unmarshal(policyDocument, &document); err != nil {
+		t.Fatalf("invalid policy document %s: %s", string(policyDocument), err.Error())
+	}
+
+	for _, condition := range document.Conditions {
+		var conditionValue interface{}
+		_ = json.Unmarshal(condition, &conditionValue)
+		conditionMarshal, _ := json.Marshal(conditionValue)
+		conditions = append(conditions, string(conditionMarshal))
+	}
+
+	sort.Strings(conditions)
+	return document.Expiration, conditions
+}
+
+func TestEncodePolicyDocument(t *testing.T) {
+	s3PolicyBase := getTestPolicy()
+	encodedPolicy, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	prepared, preparedPolicy, err := s3PolicyBase.preparePolicy()
+	if err != nil {
+		t.Fatalf("failed to prepare policy: %s", err.Error())
+	}
+
+	policy := *preparedPolicy
+	expiration := prepared.ExpiredDate.UTC().Format(ExpirationFormat)
+
+	policyDocument := encodePolicyDocument(expiration, policy.getConditions())
+	if prepared.encodePolicy(policyDocument) != encodedPolicy {
+		t.Errorf("encoded policy should be the same as generated policy")
+	}
+
+	// every string must be encoded the same as encoding/json
+	var document struct {
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	_ = json.Unmarshal(policyDocument, &document)
+	for _, condition := range document.Conditions {
+		var conditionValue interface{}
+		_ = json.Unmarshal(condition, &conditionValue)
+		conditionMarshal, _ := json.Marshal(conditionValue)
+		if string(conditionMarshal) != string(condition) {
+			t.Errorf("condition should be encoded as %s not %s", string(conditionMarshal), string(condition))
+		}
+	}
+
+	legacyDocument, legacyFormValues := legacyEncodePolicyDocument(policy, expiration)
+	legacyExpiration, legacyConditions := getSortedConditions(t, legacyDocument)
+	newExpiration, newConditions := getSortedConditions(t, policyDocument)
+	if legacyExpiration != newExpiration {
+		t.Errorf("expiration should be [%s] not [%s]", legacyExpiration, newExpiration)
+	}
+
+	if len(legacyConditions) != len(newConditions) {
+		t.Fatalf("policy should have %d conditions not %d", len(legacyConditions), len(newConditions))
+	}
+
+	for idx, condition := range legacyConditions {
+		if newConditions[idx] != condition {
+			t.Errorf("condition should be %s not %s", condition, newConditions[idx])
+		}
+	}
+
+	// the new form values have policy and x-amz-signature
+	if len(legacyFormValues)+2 != len(formsData.FormData) {
+		t.Errorf("form should have %d values not %d", len(legacyFormValues)+2, len(formsData.FormData))
+	}
+
+	for _, formValue := range legacyFormValues {
+		if !hasFormValue(formsData, formValue.FormName, formValue.FormValue) {
+			t.Errorf("form value [%s] should be [%s]", formValue.FormName, formValue.FormValue)
+		}
+	}
+}
+
+func BenchmarkGeneratePolicy(b *testing.B) {
+	s3PolicyBase := getTestPolicy()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+func BenchmarkEncodePolicyDocument(b *testing.B) {
+	s3PolicyBase := getTestPolicy()
+	policy := *s3PolicyBase.Policy
+	expiration := s3PolicyBase.ExpiredDate.UTC().Format(ExpirationFormat)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conditions := policy.getConditions()
+		_ = encodePolicyDocument(expiration, conditions)
+		_ = getFormValues(conditions)
+	}
+}
+
+func BenchmarkLegacyEncodePolicyDocument(b *testing.B) {
+	s3PolicyBase := getTestPolicy()
+	policy := *s3PolicyBase.Policy
+	expiration := s3PolicyBase.ExpiredDate.UTC().Format(ExpirationFormat)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = legacyEncodePolicyDocument(policy, expiration)
+	}
+}
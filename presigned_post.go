@@ -0,0 +1,255 @@
+package s3Presign
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPresignedPostExpiresIn default expiration of GeneratePresignedPost, the same as boto3
+const DefaultPresignedPostExpiresIn = time.Hour
+
+// FilenameVariable key ending with ${filename} use "starts-with" key condition,
+// the browser replace it with the uploaded file name.
+const FilenameVariable = "${filename}"
+
+// PresignedPost the same shape as boto3 generate_presigned_post result, {"url": "...", "fields": {...}}
+type PresignedPost struct {
+	Url    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+// GeneratePresignedPost generate the presigned post the same way as boto3 generate_presigned_post(Bucket, Key, Fields, Conditions, ExpiresIn),
+// merged with the conditions already set in the policy. The policy is not changed.
+//   - bucket: empty bucket use AwsConfig.AwsBucket, other bucket can't be used with Endpoint
+//   - fields: prefilled form fields, the same as boto3 the fields are not added to the conditions
+//   - conditions: AWS list format, {"acl": "public-read"}, ["starts-with", "$key", "user/"] or ["content-length-range", 1, 1024]
+//   - expiresIn: 0 use DefaultPresignedPostExpiresIn
+func (base *BaseS3Policy) GeneratePresignedPost(bucket, key string, fields map[string]string, conditions []interface{}, expiresIn time.Duration) (PresignedPost, error) {
+	if key == "" {
+		return PresignedPost{}, fmt.Errorf("key is required")
+	}
+
+	parsedConditions, err := ParseConditions(conditions)
+	if err != nil {
+		return PresignedPost{}, err
+	}
+
+	s3Policy := base.Clone()
+	if bucket != "" && bucket != s3Policy.AwsConfig.AwsBucket {
+		// the endpoint url is the url of the configured bucket
+		if s3Policy.Endpoint != "" {
+			return PresignedPost{}, fmt.Errorf("bucket [%s] can't be used with endpoint [%s]", bucket, s3Policy.Endpoint)
+		}
+
+		s3Policy.AwsConfig.AwsBucket = bucket
+	}
+
+	if expiresIn <= 0 {
+		expiresIn = DefaultPresignedPostExpiresIn
+	}
+
+	s3Policy.SetExpiresIn(expiresIn)
+	s3Policy.SetBucketPolicy(ConditionMatchingExactMatch, s3Policy.AwsConfig.AwsBucket)
+	if strings.HasSuffix(key, FilenameVariable) {
+		s3Policy.SetKeyPolicy(ConditionMatchingStartWith, strings.TrimSuffix(key, FilenameVariable))
+	} else {
+		s3Policy.SetKeyPolicy(ConditionMatchingExactMatch, key)
+	}
+
+	for _, condition := range parsedConditions {
+		if err = s3Policy.addCondition(condition); err != nil {
+			return PresignedPost{}, err
+		}
+	}
+
+	_, _, forms, err := s3Policy.GeneratePolicyWithError()
+	if err != nil {
+		return PresignedPost{}, err
+	}
+
+	presignedPost := PresignedPost{
+		Url:    forms.Url,
+		Fields: make(map[string]string, len(fields)+len(forms.FormData)),
+	}
+
+	for name, value := range fields {
+		presignedPost.Fields[name] = value
+	}
+
+	// the bucket is in the url, the same as boto3 it's not a field
+	for _, formData := range forms.FormData {
+		if _, ok := fields[formData.FormName]; (ok && !isSignedFormField(formData.FormName)) || formData.FormName == "bucket" {
+			continue
+		}
+
+		presignedPost.Fields[formData.FormName] = formData.FormValue
+	}
+
+	presignedPost.Fields["key"] = key
+	return presignedPost, nil
+}
+
+// ParseConditions parse conditions in AWS list format (ex: from JSON or boto3 Conditions),
+// dict {"field": "value"} is "eq" condition, array is ["eq" | "starts-with", "$field", "value"]
+// or ["content-length-range", min, max].
+func ParseConditions(conditions []interface{}) ([]Condition, error) {
+	parsedConditions := make([]Condition, 0, len(conditions))
+	for idx, rawCondition := range conditions {
+		condition, err := parseCondition(rawCondition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %d: %w", idx, err)
+		}
+
+		parsedConditions = append(parsedConditions, condition)
+	}
+
+	return parsedConditions, nil
+}
+
+func parseCondition(rawCondition interface{}) (Condition, error) {
+	switch condition := rawCondition.(type) {
+	case map[string]string:
+		for field, value := range condition {
+			if len(condition) != 1 {
+				return Condition{}, fmt.Errorf("dict condition must have one field, got %d", len(condition))
+			}
+
+			return Condition{Field: field, ConditionUsed: ConditionMatchingExactMatch, PolicyValue: value}, nil
+		}
+	case map[string]interface{}:
+		for field, value := range condition {
+			if len(condition) != 1 {
+				return Condition{}, fmt.Errorf("dict condition must have one field, got %d", len(condition))
+			}
+
+			stringValue, err := getConditionString(value)
+			if err != nil {
+				return Condition{}, err
+			}
+
+			return Condition{Field: field, ConditionUsed: ConditionMatchingExactMatch, PolicyValue: stringValue}, nil
+		}
+	case []string:
+		values := make([]interface{}, 0, len(condition))
+		for _, value := range condition {
+			values = append(values, value)
+		}
+
+		return parseCondition(values)
+	case []interface{}:
+		if len(condition) != 3 {
+			return Condition{}, fmt.Errorf("array condition must have 3 elements, got %d", len(condition))
+		}
+
+		conditionMatch, ok := condition[0].(string)
+		if !ok {
+			return Condition{}, fmt.Errorf("condition matching must be string, got %v", condition[0])
+		}
+
+		switch conditionMatch = strings.ToLower(conditionMatch); conditionMatch {
+		case ConditionMatchingExactMatch, ConditionMatchingStartWith:
+			field, ok := condition[1].(string)
+			if !ok || !strings.HasPrefix(field, "$") {
+				return Condition{}, fmt.Errorf("condition field must be string starting with $, got %v", condition[1])
+			}
+
+			value, err := getConditionString(condition[2])
+			if err != nil {
+				return Condition{}, err
+			}
+
+			return Condition{Field: strings.TrimPrefix(field, "$"), ConditionUsed: conditionMatch, PolicyValue: value}, nil
+		case ConditionSpecifyingRange:
+			startRange, err := getConditionUint(condition[1])
+			if err != nil {
+				return Condition{}, err
+			}
+
+			stopRange, err := getConditionUint(condition[2])
+			if err != nil {
+				return Condition{}, err
+			}
+
+			return Condition{
+				Field:            ConditionSpecifyingRange,
+				ConditionUsed:    ConditionSpecifyingRange,
+				PolicyStartRange: startRange,
+				PolicyStopRange:  stopRange,
+			}, nil
+		default:
+			return Condition{}, fmt.Errorf("condition matching [%s] not found", conditionMatch)
+		}
+	}
+
+	return Condition{}, fmt.Errorf("condition must be dict or array, got %v", rawCondition)
+}
+
+func getConditionString(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case int, int64, uint64, bool:
+		return fmt.Sprint(value), nil
+	}
+
+	return "", fmt.Errorf("condition value must be string, got %v", value)
+}
+
+func getConditionUint(value interface{}) (uint64, error) {
+	switch value := value.(type) {
+	case int:
+		if value >= 0 {
+			return uint64(value), nil
+		}
+	case int64:
+		if value >= 0 {
+			return uint64(value), nil
+		}
+	case uint64:
+		return value, nil
+	case float64:
+		if value >= 0 && value == float64(uint64(value)) {
+			return uint64(value), nil
+		}
+	case json.Number:
+		return strconv.ParseUint(value.String(), 10, 64)
+	case string:
+		return strconv.ParseUint(value, 10, 64)
+	}
+
+	return 0, fmt.Errorf("range value must be positive integer, got %v", value)
+}
+
+// add the condition checked with the policy field registry, return error instead of panic
+func (base *BaseS3Policy) addCondition(condition Condition) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("invalid condition for field [%s]: %v", condition.Field, recovered)
+		}
+	}()
+
+	if condition.ConditionUsed == ConditionSpecifyingRange {
+		base.AddRange(condition.Field, condition.PolicyStartRange, condition.PolicyStopRange)
+	} else {
+		base.AddCondition(condition.Field, condition.ConditionUsed, condition.PolicyValue)
+	}
+
+	return nil
+}
+
+// fields generated on signing, always use the generated value
+func isSignedFormField(name string) bool {
+	switch name {
+	case "policy", "x-amz-signature", "x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-security-token":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,168 @@
+package s3Presign
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeneratePresignedPost(t *testing.T) {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig, WithClock(clock))
+	s3PolicyBase.SetXAmzMeta("tenant", ConditionMatchingExactMatch, "acme")
+
+	// conditions decoded from JSON, the same as boto3 Conditions
+	var conditions []interface{}
+	err := json.Unmarshal([]byte(`[
+		{"acl": "private"},
+		["starts-with", "$Content-Type", "image/"],
+		["content-length-range", 1, 1048576]
+	]`), &conditions)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fields := map[string]string{"acl": "private", "Content-Type": "image/png", "policy": "ignored"}
+	presignedPost, err := s3PolicyBase.GeneratePresignedPost("other-bucket", "uploads/${filename}", fields, conditions, 0)
+	if err != nil {
+		t.Fatalf("failed to generate presigned post: %s", err.Error())
+	}
+
+	if presignedPost.Url != "https://other-bucket.s3.amazonaws.com/" {
+		t.Errorf("url should use the bucket, got %s", presignedPost.Url)
+	}
+
+	expectedFields := map[string]string{
+		"key":               "uploads/${filename}",
+		"acl":               "private",
+		"Content-Type":      "image/png",
+		"x-amz-meta-tenant": "acme",
+		"x-amz-algorithm":   "AWS4-HMAC-SHA256",
+	}
+	for name, value := range expectedFields {
+		if presignedPost.Fields[name] != value {
+			t.Errorf("field [%s] should be [%s] not [%s]", name, value, presignedPost.Fields[name])
+		}
+	}
+
+	for _, name := range []string{"policy", "x-amz-signature", "x-amz-credential", "x-amz-date"} {
+		if presignedPost.Fields[name] == "" || presignedPost.Fields[name] == "ignored" {
+			t.Errorf("field [%s] should be generated", name)
+		}
+	}
+
+	if _, ok := presignedPost.Fields["bucket"]; ok {
+		t.Errorf("bucket should not be a field, the same as boto3")
+	}
+
+	policyDocument, _ := base64.StdEncoding.DecodeString(presignedPost.Fields["policy"])
+	for _, condition := range []string{
+		`{"bucket":"other-bucket"}`,
+		`["starts-with","$key","uploads/"]`,
+		`{"acl":"private"}`,
+		`["starts-with","$Content-Type","image/"]`,
+		`["content-length-range",1,1048576]`,
+		`"expiration":"2015-12-29T01:00:00.000Z"`,
+	} {
+		if !strings.Contains(string(policyDocument), condition) {
+			t.Errorf("policy should contain %s: %s", condition, string(policyDocument))
+		}
+	}
+
+	// the same as boto3 the fields are not added to the conditions
+	if strings.Contains(string(policyDocument), "image/png") {
+		t.Errorf("fields should not be added to the conditions: %s", string(policyDocument))
+	}
+
+	// the template is not changed
+	if s3PolicyBase.AwsConfig.AwsBucket != AwsBucket || s3PolicyBase.Policy.Key.ConditionUsed != "" || len(s3PolicyBase.Policy.Conditions) != 0 {
+		t.Errorf("template policy should not be changed")
+	}
+
+	output, _ := json.Marshal(presignedPost)
+	var decoded map[string]interface{}
+	_ = json.Unmarshal(output, &decoded)
+	if _, ok := decoded["url"]; !ok || decoded["fields"] == nil {
+		t.Errorf("presigned post should be encoded as {\"url\", \"fields\"}: %s", string(output))
+	}
+}
+
+func TestGeneratePresignedPostExactKey(t *testing.T) {
+	defaultData := getDefaultData()
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+
+	presignedPost, err := s3PolicyBase.GeneratePresignedPost("", "user/test.txt", nil, nil, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("failed to generate presigned post: %s", err.Error())
+	}
+
+	policyDocument, _ := base64.StdEncoding.DecodeString(presignedPost.Fields["policy"])
+	if !strings.Contains(string(policyDocument), `{"key":"user/test.txt"}`) || !strings.Contains(string(policyDocument), `{"bucket":"`+AwsBucket+`"}`) {
+		t.Errorf("policy should have eq key and default bucket: %s", string(policyDocument))
+	}
+
+	if _, err = s3PolicyBase.GeneratePresignedPost("", "", nil, nil, 0); err == nil {
+		t.Errorf("empty key should return error")
+	}
+
+	// the endpoint is the url of the configured bucket
+	s3PolicyBase.Endpoint = "https://storage.example.com/" + AwsBucket + "/"
+	if _, err = s3PolicyBase.GeneratePresignedPost("other-bucket", "user/test.txt", nil, nil, 0); err == nil {
+		t.Errorf("other bucket with endpoint should return error")
+	}
+
+	presignedPost, err = s3PolicyBase.GeneratePresignedPost(AwsBucket, "user/test.txt", nil, nil, 0)
+	if err != nil || presignedPost.Url != s3PolicyBase.Endpoint {
+		t.Errorf("configured bucket with endpoint should use the endpoint, got %s %v", presignedPost.Url, err)
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	conditions, err := ParseConditions([]interface{}{
+		map[string]string{"success_action_status": "201"},
+		[]string{"STARTS-WITH", "$key", "user/"},
+		[]interface{}{"content-length-range", 10, int64(100)},
+	})
+	if err != nil {
+		t.Fatalf("failed to parse conditions: %s", err.Error())
+	}
+
+	expected := []Condition{
+		{Field: "success_action_status", ConditionUsed: ConditionMatchingExactMatch, PolicyValue: "201"},
+		{Field: "key", ConditionUsed: ConditionMatchingStartWith, PolicyValue: "user/"},
+		{Field: ConditionSpecifyingRange, ConditionUsed: ConditionSpecifyingRange, PolicyStartRange: 10, PolicyStopRange: 100},
+	}
+	for idx, condition := range expected {
+		if conditions[idx] != condition {
+			t.Errorf("condition %d should be %+v not %+v", idx, condition, conditions[idx])
+		}
+	}
+
+	invalidConditions := [][]interface{}{
+		{map[string]string{"acl": "private", "key": "test"}},
+		{[]string{"eq", "$key"}},
+		{[]string{"eq", "key", "test"}},
+		{[]string{"in", "$key", "test"}},
+		{[]interface{}{"content-length-range", -1, 100}},
+		{[]interface{}{"content-length-range", 1.5, 100}},
+		{"acl"},
+	}
+	for _, invalidCondition := range invalidConditions {
+		if _, err = ParseConditions(invalidCondition); err == nil {
+			t.Errorf("condition %v should return error", invalidCondition)
+		}
+	}
+
+	// unregistered field return error instead of panic
+	s3PolicyBase := NewS3Policy(getDefaultData().AwsConfig)
+	_, err = s3PolicyBase.GeneratePresignedPost("", "test.txt", nil, []interface{}{map[string]string{"unknown-field": "value"}}, 0)
+	if err == nil {
+		t.Errorf("unregistered field should return error")
+	}
+}
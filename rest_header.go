@@ -0,0 +1,150 @@
+package s3Presign
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const ContentDispositionAttachment = "attachment"
+const ContentDispositionInline = "inline"
+
+// CacheControl builder for the Cache-Control header value.
+// See https://www.rfc-editor.org/rfc/rfc9111#section-5.2.2, for more details
+type CacheControl struct {
+	MaxAge     time.Duration // max-age, in seconds precision, only used when more than 0
+	ZeroMaxAge bool          // use max-age=0 when MaxAge is 0
+	Public     bool
+	Private    bool // can't be used with Public
+	NoCache    bool
+	NoStore    bool
+	Immutable  bool
+}
+
+// Validate check the directives can be used together
+func (cacheControl CacheControl) Validate() error {
+	if cacheControl.Public && cacheControl.Private {
+		return fmt.Errorf("cache control can't be both public and private")
+	}
+
+	if cacheControl.MaxAge < 0 {
+		return fmt.Errorf("cache control max-age can't be negative, got %s", cacheControl.MaxAge)
+	}
+
+	return nil
+}
+
+func (cacheControl CacheControl) String() string {
+	var directives []string
+	if cacheControl.Public {
+		directives = append(directives, "public")
+	}
+
+	if cacheControl.Private {
+		directives = append(directives, "private")
+	}
+
+	if cacheControl.NoCache {
+		directives = append(directives, "no-cache")
+	}
+
+	if cacheControl.NoStore {
+		directives = append(directives, "no-store")
+	}
+
+	if cacheControl.MaxAge > 0 || cacheControl.ZeroMaxAge {
+		directives = append(directives, fmt.Sprintf("max-age=%d", int64(cacheControl.MaxAge/time.Second)))
+	}
+
+	if cacheControl.Immutable {
+		directives = append(directives, "immutable")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// ContentDisposition builder for the Content-Disposition header value.
+// Non-ASCII filename is encoded as filename* (RFC 5987) with an ASCII filename fallback,
+// See https://www.rfc-editor.org/rfc/rfc6266#section-4.3, for more details
+type ContentDisposition struct {
+	Type     string // ContentDispositionAttachment or ContentDispositionInline, default attachment
+	Filename string
+}
+
+func (contentDisposition ContentDisposition) String() string {
+	dispositionType := contentDisposition.Type
+	if dispositionType == "" {
+		dispositionType = ContentDispositionAttachment
+	}
+
+	if contentDisposition.Filename == "" {
+		return dispositionType
+	}
+
+	filename := contentDisposition.Filename
+	if isASCII(filename) {
+		return fmt.Sprintf("%s; filename=%s", dispositionType, quoteString(filename))
+	}
+
+	fallback := strings.Map(func(char rune) rune {
+		if char >= 0x80 {
+			return '_'
+		}
+
+		return char
+	}, filename)
+
+	return fmt.Sprintf("%s; filename=%s; filename*=UTF-8''%s", dispositionType, quoteString(fallback), encodeExtValue(filename))
+}
+
+// SetCacheControl set Cache-Control policy with "eq" condition from the builder, panic if the directives are invalid
+func (base *BaseS3Policy) SetCacheControl(cacheControl CacheControl) *BaseS3Policy {
+	if err := cacheControl.Validate(); err != nil {
+		panic(err.Error())
+	}
+
+	return base.SetCacheControlPolicy(ConditionMatchingExactMatch, cacheControl.String())
+}
+
+// SetContentDisposition set Content-Disposition policy with "eq" condition from the builder
+func (base *BaseS3Policy) SetContentDisposition(contentDisposition ContentDisposition) *BaseS3Policy {
+	return base.SetContentDispositionPolicy(ConditionMatchingExactMatch, contentDisposition.String())
+}
+
+// quoted-string (RFC 7230 section 3.2.6), control characters are removed
+func quoteString(value string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch char := value[i]; {
+		case char == '"' || char == '\\':
+			builder.WriteByte('\\')
+			builder.WriteByte(char)
+		case char < 0x20 || char == 0x7f:
+			continue
+		default:
+			builder.WriteByte(char)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+// percent encode value except attr-char (RFC 5987 section 3.2.1)
+func encodeExtValue(value string) string {
+	const attrChar = "!#$&+-.^_`|~"
+
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9',
+			strings.IndexByte(attrChar, char) >= 0:
+			builder.WriteByte(char)
+		default:
+			builder.WriteString(fmt.Sprintf("%%%02X", char))
+		}
+	}
+
+	return builder.String()
+}
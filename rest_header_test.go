@@ -0,0 +1,54 @@
+package s3Presign
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheControl(t *testing.T) {
+	testValue := map[string]CacheControl{
+		"public, max-age=31536000, immutable": {Public: true, MaxAge: 365 * 24 * time.Hour, Immutable: true},
+		"private, no-store":                   {Private: true, NoStore: true},
+		"no-cache":                            {NoCache: true},
+		"no-cache, max-age=0":                 {NoCache: true, ZeroMaxAge: true},
+	}
+
+	for testResult, cacheControl := range testValue {
+		if result := cacheControl.String(); result != testResult {
+			t.Errorf("cache control should be [%s] not [%s]", testResult, result)
+		}
+	}
+
+	invalidValues := []CacheControl{{Public: true, Private: true}, {MaxAge: -time.Second}}
+	for _, cacheControl := range invalidValues {
+		if err := cacheControl.Validate(); err == nil {
+			t.Errorf("cache control %+v should return error", cacheControl)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("public and private cache control should panic")
+			}
+		}()
+
+		NewS3Policy(AwsConfig{}).SetCacheControl(CacheControl{Public: true, Private: true})
+	}()
+}
+
+func TestContentDisposition(t *testing.T) {
+	testValue := map[string]ContentDisposition{
+		"attachment":                             {},
+		"inline":                                 {Type: ContentDispositionInline},
+		`attachment; filename="test.jpeg"`:       {Filename: "test.jpeg"},
+		`attachment; filename="my \"file\".txt"`: {Filename: `my "file".txt`},
+		`attachment; filename="_t_.txt"; filename*=UTF-8''%E2%82%ACt%C3%A9.txt`: {Filename: "€té.txt"},
+	}
+
+	for testResult, contentDisposition := range testValue {
+		if result := contentDisposition.String(); result != testResult {
+			t.Errorf("content disposition should be [%s] not [%s]", testResult, result)
+		}
+	}
+}
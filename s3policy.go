@@ -5,8 +5,10 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
@@ -21,12 +23,16 @@ const ConditionSpecifyingRange = "content-length-range"
 const ExpirationFormat = "2006-01-02T15:04:05.000Z"
 const SignatureDateFormat = "20060102"
 const AmzDateFormat = "20060102T150405Z"
-const ExpiredHeaderFormat = "Mon, 02 Jan 2006 15:04:05 MST"
+const ExpiredHeaderFormat = http.TimeFormat // HTTP-date (RFC 7231), time must be in UTC
 
 const AmzAlgorithm = "AWS4-HMAC-SHA256"
 const XAmzMetaKey = "x-amz-meta-"
 const XAmzKey = "x-amz-"
 
+// MaxXAmzMetaSize S3 limits user-defined metadata to 2 KB, measured as the sum of
+// the UTF-8 bytes of each metadata name (without the x-amz-meta- prefix) and value.
+const MaxXAmzMetaSize = 2048
+
 // ExactMatch The form field value must match the value specified.
 // This example indicates that the ACL must be set to public-read:
 // {"acl": "public-read" }
@@ -43,7 +49,6 @@ type StartWith []string
 type SpecifyingRange []interface{}
 
 type PolicyConditions struct {
-	Conditions    ConditionMatching
 	ConditionUsed string
 
 	PolicyValue      string
@@ -73,6 +78,7 @@ type Policy struct {
 	ContentType        PolicyConditions `json:"Content-Type"`
 	ContentDisposition PolicyConditions `json:"Content-Disposition"`
 	ContentEncoding    PolicyConditions `json:"Content-Encoding"`
+	ContentLanguage    PolicyConditions `json:"Content-Language"`
 	Expires            PolicyConditions `json:"Expires"`
 
 	// The acceptable key name or a prefix of the uploaded object.
@@ -109,6 +115,7 @@ type Policy struct {
 	// As a result, the values must be separated by commas.
 	// For example, if the user token is eW91dHViZQ== and the product token is b0hnNVNKWVJIQTA=,
 	// you set the POST policy entry to: { "x-amz-security-token": "eW91dHViZQ==,b0hnNVNKWVJIQTA=" }.
+	// It can't be used with AwsConfig.AwsSessionToken, the session token is set to this field on generation.
 	XAmzSecurityToken PolicyConditions `json:"x-amz-security-token"`
 
 	// x-amz-meta-*
@@ -121,12 +128,20 @@ type Policy struct {
 	// Headers starting with this prefix are for any x-amz-* headers
 	// See https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOST.html, for more details
 	XAmz map[string]PolicyConditions `json:"x_amz"`
+
+	// Conditions added with AddCondition and AddRange, in the order they are added.
+	// These conditions are added after the conditions of the fields above.
+	Conditions []Condition `json:"-"`
+
+	// Content types allowed by AllowContentTypes, S3 only see the Content-Type condition,
+	// this list is used to check the key extension on generation.
+	AllowedContentTypes []string `json:"-"`
 }
 
+// Validate check the policy fields with the policy field registry,
+// the same as GeneratePolicyWithError without the fields set on generation
 func (policy Policy) Validate() error {
-	return validation.ValidateStruct(&policy,
-		validation.Field(&policy.Key, validation.Required),
-	)
+	return policy.validateFields(false)
 }
 
 type AwsConfig struct {
@@ -134,6 +149,13 @@ type AwsConfig struct {
 	AwsRegion    string // used for creating signature
 	AwsSecretKey string // used for creating signature
 	AwsBucket    string
+
+	// temporary credentials (ex: from STS or assumed role), the session token is added as x-amz-security-token
+	// and the policy expiration can't be after the credential expiry, see BaseS3Policy.CredentialExpiryMode
+	AwsSessionToken  string
+	CredentialExpiry time.Time
+
+	Guardrails *Guardrails // enforced by GeneratePolicyWithError, in addition to global guardrails
 }
 
 func (config AwsConfig) Validate() error {
@@ -151,29 +173,77 @@ type BaseS3Policy struct {
 	Date        time.Time // used for creating signature
 	ExpiredDate time.Time
 	Policy      *Policy
-}
 
-func NewS3Policy(config AwsConfig) *BaseS3Policy {
-	defaultPolicy := getPolicyConfig()
-	if err := defaultPolicy.Validate(); err != nil {
-		panic(err.Error())
-	}
+	// XAmzMetaEncoding encode non-ASCII x-amz-meta-* values as RFC 2047 encoded-words,
+	// without it S3 can't store the value as is and the policy is rejected on generation.
+	XAmzMetaEncoding bool
+
+	// ContentTypeFromKey derive the Content-Type value from the key extension on generation.
+	ContentTypeFromKey bool
+
+	// StrictLint refuse to generate the policy if the linter found error, using LintRules
+	// or DefaultLintRules if LintRules is empty.
+	StrictLint bool
+	LintRules  []LintRule
+
+	// Endpoint the form url, default is https://<bucket>.s3.amazonaws.com/
+	// ex: https://<bucket>.s3.us-west-2.amazonaws.com/ or http://localhost:9000/<bucket>/ for S3 compatible storages
+	Endpoint string
+
+	// Clock used for the signing date, default is system clock
+	Clock Clock
+
+	// ExpiresIn the policy expiration relative to the signing date, used instead of ExpiredDate when more than 0.
+	ExpiresIn time.Duration
+
+	// MinTTL and MaxTTL bounds of the time between the signing date and the policy expiration,
+	// zero value means no bound.
+	MinTTL time.Duration
+	MaxTTL time.Duration
+
+	// CredentialExpiryMode what to do when the policy expiration is after AwsConfig.CredentialExpiry,
+	// CredentialExpiryClamp (default) or CredentialExpiryError
+	CredentialExpiryMode string
 
+	stampedDate time.Time        // the signing date set automatically, re-stamped on generation
+	signingKeys *signingKeyCache // cached signing key, shared with the clones
+}
+
+func NewS3Policy(config AwsConfig, options ...Option) *BaseS3Policy {
 	base := BaseS3Policy{
 		AwsConfig:  config,
 		AwsService: "s3",
-		Policy:     defaultPolicy,
+		Policy:     &Policy{},
+		Clock:      SystemClock,
+		ExpiresIn:  time.Minute * 10, // default expired 10 minutes
+
+		signingKeys: &signingKeyCache{},
+	}
+
+	for _, option := range options {
+		option(&base)
+	}
+
+	base.Date = base.Clock.Now()
+	base.stampedDate = base.Date
+	if base.ExpiresIn > 0 {
+		base.ExpiredDate = base.Date.Add(base.ExpiresIn)
 	}
 
-	timeNow := time.Now()
-	expirationDateDefault := timeNow.Add(time.Minute * 10) // default expired 10 minutes
-	base.Date = timeNow
-	base.ExpiredDate = expirationDateDefault
 	return &base
 }
 
+// SetExpirationDate set the policy expiration to absolute time
 func (base *BaseS3Policy) SetExpirationDate(expirationDate time.Time) *BaseS3Policy {
 	base.ExpiredDate = expirationDate
+	base.ExpiresIn = 0
+	return base
+}
+
+// SetXAmzMetaEncoding enable or disable RFC 2047 encoding of non-ASCII x-amz-meta-* values.
+// Use DecodeXAmzMeta to read the values back from the object headers.
+func (base *BaseS3Policy) SetXAmzMetaEncoding(encode bool) *BaseS3Policy {
+	base.XAmzMetaEncoding = encode
 	return base
 }
 
@@ -189,7 +259,7 @@ func (base *BaseS3Policy) setXAmzCredentialPolicy() *BaseS3Policy {
 	accessKey := base.AwsConfig.AwsAccessKey
 	credentialDate := base.Date.UTC().Format(SignatureDateFormat)
 	region := base.AwsConfig.AwsRegion
-	service := "s3" // default s3
+	service := base.AwsService
 	awsSignatureVersion := "aws4_request"
 	policyValue := fmt.Sprintf("%s/%s/%s/%s/%s", accessKey, credentialDate, region, service, awsSignatureVersion)
 
@@ -204,7 +274,7 @@ func (base *BaseS3Policy) setXAmzDatePolicy() *BaseS3Policy {
 }
 
 func (base *BaseS3Policy) SetAclPolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.Acl.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("acl"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -215,7 +285,7 @@ func (base *BaseS3Policy) SetAclPolicy(conditionMatch, value string) *BaseS3Poli
 }
 
 func (base *BaseS3Policy) SetBucketPolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.Bucket.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("bucket"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -233,7 +303,7 @@ func (base *BaseS3Policy) SetContentLengthPolicy(min, max uint64) *BaseS3Policy
 }
 
 func (base *BaseS3Policy) SetCacheControlPolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.CacheControl.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("Cache-Control"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -244,7 +314,7 @@ func (base *BaseS3Policy) SetCacheControlPolicy(conditionMatch, value string) *B
 }
 
 func (base *BaseS3Policy) SetContentTypePolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.ContentType.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("Content-Type"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -255,7 +325,7 @@ func (base *BaseS3Policy) SetContentTypePolicy(conditionMatch, value string) *Ba
 }
 
 func (base *BaseS3Policy) SetContentDispositionPolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.ContentDisposition.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("Content-Disposition"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -266,7 +336,7 @@ func (base *BaseS3Policy) SetContentDispositionPolicy(conditionMatch, value stri
 }
 
 func (base *BaseS3Policy) SetContentEncodingPolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.ContentEncoding.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("Content-Encoding"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -276,8 +346,19 @@ func (base *BaseS3Policy) SetContentEncodingPolicy(conditionMatch, value string)
 	return base
 }
 
+func (base *BaseS3Policy) SetContentLanguagePolicy(conditionMatch, value string) *BaseS3Policy {
+	canBeUsed := checkConditions(getFieldConditions("Content-Language"), conditionMatch)
+	if !canBeUsed {
+		panic("condition matching type can't be used")
+	}
+
+	base.Policy.ContentLanguage.ConditionUsed = conditionMatch
+	base.Policy.ContentLanguage.PolicyValue = value
+	return base
+}
+
 func (base *BaseS3Policy) SetExpiresPolicy(value time.Time) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.Expires.Conditions, ConditionMatchingExactMatch)
+	canBeUsed := checkConditions(getFieldConditions("Expires"), ConditionMatchingExactMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -288,7 +369,7 @@ func (base *BaseS3Policy) SetExpiresPolicy(value time.Time) *BaseS3Policy {
 }
 
 func (base *BaseS3Policy) SetKeyPolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.Key.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("key"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -299,7 +380,7 @@ func (base *BaseS3Policy) SetKeyPolicy(conditionMatch, value string) *BaseS3Poli
 }
 
 func (base *BaseS3Policy) SetSuccessActionRedirectPolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.SuccessActionRedirect.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("success_action_redirect"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -310,7 +391,7 @@ func (base *BaseS3Policy) SetSuccessActionRedirectPolicy(conditionMatch, value s
 }
 
 func (base *BaseS3Policy) SetSuccessActionStatusPolicy(conditionMatch, value string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.SuccessActionStatus.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("success_action_status"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -321,7 +402,7 @@ func (base *BaseS3Policy) SetSuccessActionStatusPolicy(conditionMatch, value str
 }
 
 func (base *BaseS3Policy) SetXAmzSecurityTokenPolicy(conditionMatch, userToken, productToken string) *BaseS3Policy {
-	canBeUsed := checkConditions(base.Policy.XAmzSecurityToken.Conditions, conditionMatch)
+	canBeUsed := checkConditions(getFieldConditions("x-amz-security-token"), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
@@ -332,18 +413,13 @@ func (base *BaseS3Policy) SetXAmzSecurityTokenPolicy(conditionMatch, userToken,
 }
 
 func (base *BaseS3Policy) SetXAmzMeta(key, conditionMatch, value string) *BaseS3Policy {
-	condition := ConditionMatching{
-		ExactMatch: true,
-		StartWith:  true,
-	}
-
-	canBeUsed := checkConditions(condition, conditionMatch)
+	keyPolicy := getCustomKey(key, XAmzMetaKey)
+	canBeUsed := checkConditions(getFieldConditions(keyPolicy), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
 
 	amzMeta := PolicyConditions{
-		Conditions:    condition,
 		ConditionUsed: conditionMatch,
 		PolicyValue:   value,
 	}
@@ -353,26 +429,19 @@ func (base *BaseS3Policy) SetXAmzMeta(key, conditionMatch, value string) *BaseS3
 		xAmzMeta = map[string]PolicyConditions{}
 	}
 
-	keyPolicy := getCustomKey(key, XAmzMetaKey)
-	xAmzMeta[keyPolicy] = amzMeta
-
 	xAmzMeta[keyPolicy] = amzMeta
 	base.Policy.XAmzMeta = xAmzMeta
 	return base
 }
 
 func (base *BaseS3Policy) SetXAmz(key, conditionMatch, value string) *BaseS3Policy {
-	condition := ConditionMatching{
-		ExactMatch: true,
-	}
-
-	canBeUsed := checkConditions(condition, conditionMatch)
+	keyPolicy := getCustomKey(key, XAmzKey)
+	canBeUsed := checkConditions(getFieldConditions(keyPolicy), conditionMatch)
 	if !canBeUsed {
 		panic("condition matching type can't be used")
 	}
 
 	amz := PolicyConditions{
-		Conditions:    condition,
 		ConditionUsed: conditionMatch,
 		PolicyValue:   value,
 	}
@@ -382,50 +451,162 @@ func (base *BaseS3Policy) SetXAmz(key, conditionMatch, value string) *BaseS3Poli
 		xAmz = map[string]PolicyConditions{}
 	}
 
-	keyPolicy := getCustomKey(key, XAmzKey)
 	xAmz[keyPolicy] = amz
 
 	base.Policy.XAmz = xAmz
 	return base
 }
 
-func (base *BaseS3Policy) GeneratePolicy() (policy, signature string, form Forms) {
-	base.setXAmzAlgorithmPolicy()
-	base.setXAmzCredentialPolicy()
-	base.setXAmzDatePolicy()
+// XAmzMetaSize return the size of the user-defined metadata as counted by S3.
+// For starts-with conditions only the prefix is known, so the size is the lower bound.
+func (base *BaseS3Policy) XAmzMetaSize() int {
+	return getXAmzMetaSize(base.Policy.XAmzMeta)
+}
 
-	if base.Policy.Bucket.ConditionUsed == "" {
-		base.SetBucketPolicy(ConditionMatchingExactMatch, base.AwsConfig.AwsBucket)
+// validate and encode (if enabled) the user-defined metadata before it's used in the policy,
+// including the x-amz-meta-* conditions added with AddCondition
+func (base *BaseS3Policy) getXAmzMeta() (map[string]PolicyConditions, []Condition, error) {
+	xAmzMeta := base.Policy.XAmzMeta
+	if len(xAmzMeta) > 0 {
+		xAmzMeta = make(map[string]PolicyConditions, len(base.Policy.XAmzMeta))
+		for key, value := range base.Policy.XAmzMeta {
+			value, err := base.getXAmzMetaValue(key, value)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			xAmzMeta[key] = value
+		}
 	}
 
-	var policyData map[string]interface{}
-	policyDataMarshal, _ := json.Marshal(base.Policy)
-	_ = json.Unmarshal(policyDataMarshal, &policyData)
-
-	var policyConditions []interface{}
-	var formValue []FormData
-	for idx, value := range policyData {
-		if value == nil {
+	// the conditions of the same name are the same header, only counted once in the size
+	conditions := append([]Condition(nil), base.Policy.Conditions...)
+	conditionsMeta := map[string]PolicyConditions{}
+	for idx, condition := range conditions {
+		key := strings.ToLower(condition.Field)
+		if !strings.HasPrefix(key, XAmzMetaKey) {
 			continue
 		}
 
-		conditions, formData := getElementPolicy(idx, value.(map[string]interface{}))
-		if conditions == nil {
-			continue
+		value, err := base.getXAmzMetaValue(key, PolicyConditions{ConditionUsed: condition.ConditionUsed, PolicyValue: condition.PolicyValue})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		conditions[idx].PolicyValue = value.PolicyValue
+		if _, ok := xAmzMeta[key]; !ok && len(value.PolicyValue) >= len(conditionsMeta[key].PolicyValue) {
+			conditionsMeta[key] = value
 		}
+	}
 
-		policyConditions = append(policyConditions, conditions...)
-		formValue = append(formValue, formData...)
+	if size := getXAmzMetaSize(xAmzMeta) + getXAmzMetaSize(conditionsMeta); size > MaxXAmzMetaSize {
+		return nil, nil, fmt.Errorf("x-amz-meta size is %d bytes, maximum allowed is %d bytes", size, MaxXAmzMetaSize)
 	}
 
-	newPolicy := map[string]interface{}{
-		"expiration": base.ExpiredDate.UTC().Format(ExpirationFormat),
-		"conditions": policyConditions,
+	return xAmzMeta, conditions, nil
+}
+
+// validate the user-defined metadata name and value, the non-ASCII value is encoded if enabled
+func (base *BaseS3Policy) getXAmzMetaValue(key string, value PolicyConditions) (PolicyConditions, error) {
+	if !isValidHeaderName(key) || key == XAmzMetaKey {
+		return PolicyConditions{}, fmt.Errorf("x-amz-meta name [%s] is not a valid header name", key)
 	}
 
-	newPolicyMarshal, _ := json.Marshal(newPolicy)
-	encodedPolicy := base.encodePolicy(newPolicyMarshal)
-	signature = base.generateSignature(encodedPolicy)
+	if !isASCII(value.PolicyValue) {
+		if !base.XAmzMetaEncoding {
+			return PolicyConditions{}, fmt.Errorf("x-amz-meta [%s] value contains non-ASCII characters, enable XAmzMetaEncoding to encode it", key)
+		}
+
+		if value.ConditionUsed != ConditionMatchingExactMatch {
+			return PolicyConditions{}, fmt.Errorf("x-amz-meta [%s] non-ASCII value can only be encoded with %s condition", key, ConditionMatchingExactMatch)
+		}
+
+		value.PolicyValue = encodeXAmzMetaValue(value.PolicyValue)
+	}
+
+	return value, nil
+}
+
+// prepare the policy for generation on a copy of the base, the base is not changed: stamp the signing date,
+// set default fields, validate and encode the user-defined metadata, validate the fields, check the content type and the conflicting conditions.
+// Return the copy with the signing date and expiration of this generation, and the policy used for generation.
+func (base *BaseS3Policy) preparePolicy() (*BaseS3Policy, *Policy, error) {
+	prepared := base.Clone()
+	if prepared.Policy == nil {
+		prepared.Policy = &Policy{}
+	}
+
+	if err := prepared.stampDate(); err != nil {
+		return nil, nil, err
+	}
+
+	xAmzMeta, conditions, err := prepared.getXAmzMeta()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prepared.setXAmzAlgorithmPolicy()
+	prepared.setXAmzCredentialPolicy()
+	prepared.setXAmzDatePolicy()
+
+	if prepared.Policy.Bucket.ConditionUsed == "" {
+		prepared.SetBucketPolicy(ConditionMatchingExactMatch, prepared.AwsConfig.AwsBucket)
+	}
+
+	// the session token of this generation, so a refreshed token is used by the next generation
+	policyBase := *prepared.Policy
+	policyBase.XAmzMeta = xAmzMeta
+	policyBase.Conditions = conditions
+	if prepared.AwsConfig.AwsSessionToken != "" {
+		// the form has one x-amz-security-token field, so the session token can't be sent with the DevPay token
+		if policyBase.XAmzSecurityToken.ConditionUsed != "" {
+			return nil, nil, fmt.Errorf("x-amz-security-token DevPay token can't be used with the session token of temporary credentials")
+		}
+
+		policyBase.XAmzSecurityToken.ConditionUsed = ConditionMatchingExactMatch
+		policyBase.XAmzSecurityToken.PolicyValue = prepared.AwsConfig.AwsSessionToken
+	}
+
+	if err = policyBase.validateFields(true); err != nil {
+		return nil, nil, err
+	}
+
+	if err = prepared.checkContentType(&policyBase); err != nil {
+		return nil, nil, err
+	}
+
+	// ex: the condition added with AddCondition doesn't match the policy field
+	if conflicts := policyBase.getConflicts(); len(conflicts) > 0 {
+		return nil, nil, MergeConflictError{Conflicts: conflicts}
+	}
+
+	return prepared, &policyBase, nil
+}
+
+// GeneratePolicyWithError sign the policy and get the form, the base is not changed so it can be used as template.
+// The signing date is re-stamped with the clock on every generation (if it's not set manually).
+func (base *BaseS3Policy) GeneratePolicyWithError() (policy, signature string, form Forms, err error) {
+	prepared, policyBase, err := base.preparePolicy()
+	if err != nil {
+		return "", "", Forms{}, err
+	}
+
+	conditions := policyBase.getConditions()
+	if err = prepared.checkGuardrails(conditions); err != nil {
+		return "", "", Forms{}, err
+	}
+
+	if prepared.StrictLint {
+		if err = getLintError(prepared.lintConditions(conditions, prepared.LintRules)); err != nil {
+			return "", "", Forms{}, err
+		}
+	}
+
+	formValue := getFormValues(conditions)
+
+	policyDocument := encodePolicyDocument(prepared.ExpiredDate.UTC().Format(ExpirationFormat), conditions)
+	encodedPolicy := prepared.encodePolicy(policyDocument)
+	signature = prepared.generateSignature(encodedPolicy)
 
 	formValue = append(formValue, FormData{
 		FormName:  "policy",
@@ -438,11 +619,35 @@ func (base *BaseS3Policy) GeneratePolicy() (policy, signature string, form Forms
 	})
 
 	forms := Forms{
-		Url:      fmt.Sprintf("https://%s.%s/", base.AwsConfig.AwsBucket, "s3.amazonaws.com"),
-		FormData: formValue,
+		Url:        prepared.getUrl(),
+		FormData:   formValue,
+		Expiration: prepared.ExpiredDate,
+		Conditions: conditions,
+
+		AllowedContentTypes: append([]string(nil), policyBase.AllowedContentTypes...),
+	}
+
+	return encodedPolicy, signature, forms, nil
+}
+
+// GeneratePolicy sign the policy and get the form, the values are empty if the policy can't be generated.
+//
+// Deprecated: the error is not returned, use GeneratePolicyWithError.
+func (base *BaseS3Policy) GeneratePolicy() (policy, signature string, form Forms) {
+	policy, signature, form, err := base.GeneratePolicyWithError()
+	if err != nil {
+		return "", "", Forms{}
+	}
+
+	return policy, signature, form
+}
+
+func (base *BaseS3Policy) getUrl() string {
+	if base.Endpoint != "" {
+		return base.Endpoint
 	}
 
-	return encodedPolicy, signature, forms
+	return fmt.Sprintf("https://%s.%s/", base.AwsConfig.AwsBucket, "s3.amazonaws.com")
 }
 
 func (base *BaseS3Policy) encodePolicy(newPolicy []byte) string {
@@ -451,16 +656,49 @@ func (base *BaseS3Policy) encodePolicy(newPolicy []byte) string {
 }
 
 func (base *BaseS3Policy) generateSignature(policy string) string {
-	makeHmac := func(key []byte, data []byte) []byte {
-		hash := hmac.New(sha256.New, key)
-		hash.Write(data)
-		return hash.Sum(nil)
+	signature := makeHmac(base.getSigningKey(), []byte(policy))
+	return hex.EncodeToString(signature)
+}
+
+// get the signing key derived from the secret key and the credential scope,
+// the key is cached and derived again if the scope changed
+func (base *BaseS3Policy) getSigningKey() []byte {
+	signingDate := base.Date.UTC().Format(SignatureDateFormat)
+	cache := base.signingKeys
+	if cache == nil {
+		return deriveSigningKey(base.AwsConfig.AwsSecretKey, signingDate, base.AwsConfig.AwsRegion, base.AwsService)
 	}
 
-	h1 := makeHmac([]byte("AWS4"+base.AwsConfig.AwsSecretKey), []byte(base.Date.UTC().Format(SignatureDateFormat)))
-	h2 := makeHmac(h1, []byte(base.AwsConfig.AwsRegion))
-	h3 := makeHmac(h2, []byte(base.AwsService))
-	h4 := makeHmac(h3, []byte("aws4_request"))
-	signature := makeHmac(h4, []byte(policy))
-	return hex.EncodeToString(signature)
+	// the secret key is hashed, so it's not kept in the cache
+	secretHash := sha256.Sum256([]byte(base.AwsConfig.AwsSecretKey))
+	scope := strings.Join([]string{hex.EncodeToString(secretHash[:]), signingDate, base.AwsConfig.AwsRegion, base.AwsService}, "/")
+
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.key == nil || cache.scope != scope {
+		cache.scope = scope
+		cache.key = deriveSigningKey(base.AwsConfig.AwsSecretKey, signingDate, base.AwsConfig.AwsRegion, base.AwsService)
+	}
+
+	return cache.key
+}
+
+// derive the SigV4 signing key from the secret key and the credential scope
+func deriveSigningKey(secretKey, signingDate, region, service string) []byte {
+	h1 := makeHmac([]byte("AWS4"+secretKey), []byte(signingDate))
+	h2 := makeHmac(h1, []byte(region))
+	h3 := makeHmac(h2, []byte(service))
+	return makeHmac(h3, []byte("aws4_request"))
+}
+
+type signingKeyCache struct {
+	sync.Mutex
+	scope string
+	key   []byte
+}
+
+func makeHmac(key []byte, data []byte) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write(data)
+	return hash.Sum(nil)
 }
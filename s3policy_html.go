@@ -3,32 +3,55 @@ package s3Presign
 import (
 	"bytes"
 	"html/template"
+	"io"
+	"time"
 )
 
-var htmlDocuments = `
+// the default form, blocks "head", "header", "fields", "file" and "submit" can be replaced with NewFormTemplate.
+// S3 ignore the fields after the file, so the "file" block must be after the "fields" block.
+var htmlDocuments = `{{ define "presign" -}}
 <html>
 <head>
   <meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
+  {{- block "head" . }}{{ if .CSRFToken }}
+  <meta name="csrf-token" content="{{ .CSRFToken }}" />
+  {{- end }}{{ end }}
 </head>
 <body>
-  <h1>AWS S3 File Uploader</h1>
+  {{ block "header" . }}<h1>{{ .Title }}</h1>{{ end }}
   <form action="{{.Url}}" method="post" enctype="multipart/form-data">
+	{{- block "fields" . }}
 	{{ range $val := .FormData }}
 		<input type="hidden" name="{{ $val.FormName }}" value="{{ .FormValue }}" />
     {{ end }}
+	{{- end }}
 
-	<h3>File</h3>
-    <input type="file"   name="file" /> <br/>
+	{{ block "file" . -}}
+	<h3>{{ .FileLabel }}</h3>
+    <input type="file"   name="{{ .FileField }}"{{ if .Accept }} accept="{{ .Accept }}"{{ end }} /> <br/>
+	{{- end }}
     <!-- The elements after this will be ignored -->
-    <input type="submit" name="submit" value="Upload to Amazon S3" />
+    {{ block "submit" . }}<input type="submit" name="submit" value="{{ .SubmitLabel }}" />{{ end }}
   </form>
 </body>
 </html>
-`
+{{ end }}`
+
+var defaultFormTemplate = template.Must(template.New("presign").Parse(htmlDocuments))
 
 type Forms struct {
 	Url      string
 	FormData []FormData
+
+	// Expiration the effective policy expiration, the client must request a new form after this time
+	Expiration time.Time
+
+	// Conditions the signed policy conditions, used to check the file before upload. It's not encoded to JSON.
+	Conditions []Condition
+
+	// AllowedContentTypes the content types set by AllowContentTypes, the policy only has the common prefix.
+	// Used with Conditions to check the file before upload, it's not encoded to JSON.
+	AllowedContentTypes []string
 }
 
 type FormData struct {
@@ -36,17 +59,95 @@ type FormData struct {
 	FormValue string
 }
 
-func GenerateFormHtml(formData Forms) (string, error) {
+// FormOptions data used by the form template, empty labels use the default labels
+type FormOptions struct {
+	Title       string // default "AWS S3 File Uploader"
+	FileLabel   string // default "File"
+	SubmitLabel string // default "Upload to Amazon S3"
+	DropLabel   string // default "or drop the file here", used by the uploader
+	Accept      string // accept attribute of the file input, ex: "image/png,image/jpeg"
+	CSRFToken   string // added as csrf-token meta tag by the default template
+	Nonce       string // CSP nonce for inline script and style in custom blocks
+
+	// Data any other data used by custom template
+	Data map[string]interface{}
+}
+
+// FormTemplateData the data executed by the form template
+type FormTemplateData struct {
+	Forms
+	FormOptions
+
+	// FileField name of the file input
+	FileField string
+}
+
+// FormTemplate html/template used to render the upload form
+type FormTemplate struct {
+	template *template.Template
+	name     string
+}
+
+// NewFormTemplate create the default form template with blocks replaced, ex:
+//
+//	{{ define "header" }}<h1>Upload avatar</h1>{{ end }}
+//	{{ define "submit" }}<button type="submit">Upload</button>{{ end }}
+func NewFormTemplate(blocks string) (*FormTemplate, error) {
+	// html/template can't be cloned after executed, so parse the default form again
 	htmlTemplate, err := template.New("presign").Parse(htmlDocuments)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	if _, err = htmlTemplate.Parse(blocks); err != nil {
+		return nil, err
+	}
+
+	return &FormTemplate{template: htmlTemplate, name: "presign"}, nil
+}
+
+// NewFormTemplateFrom use the template as the form template, it's executed with FormTemplateData.
+// The template must add the file input after the form fields.
+func NewFormTemplateFrom(htmlTemplate *template.Template) *FormTemplate {
+	return &FormTemplate{template: htmlTemplate, name: htmlTemplate.Name()}
+}
+
+// Render write the form html to writer
+func (formTemplate *FormTemplate) Render(writer io.Writer, formData Forms, options FormOptions) error {
+	return formTemplate.template.ExecuteTemplate(writer, formTemplate.name, getFormTemplateData(formData, options))
+}
+
+// RenderFormHtml write the default form html to writer
+func RenderFormHtml(writer io.Writer, formData Forms, options FormOptions) error {
+	return defaultFormTemplate.ExecuteTemplate(writer, "presign", getFormTemplateData(formData, options))
+}
+
+func GenerateFormHtml(formData Forms) (string, error) {
 	bodyBuffer := bytes.NewBufferString("")
-	err = htmlTemplate.Execute(bodyBuffer, formData)
+	err := RenderFormHtml(bodyBuffer, formData, FormOptions{})
 	if err != nil {
 		return "", err
 	}
 
 	return bodyBuffer.String(), nil
 }
+
+func getFormTemplateData(formData Forms, options FormOptions) FormTemplateData {
+	if options.Title == "" {
+		options.Title = "AWS S3 File Uploader"
+	}
+
+	if options.FileLabel == "" {
+		options.FileLabel = "File"
+	}
+
+	if options.SubmitLabel == "" {
+		options.SubmitLabel = "Upload to Amazon S3"
+	}
+
+	if options.DropLabel == "" {
+		options.DropLabel = "or drop the file here"
+	}
+
+	return FormTemplateData{Forms: formData, FormOptions: options, FileField: FormFileField}
+}
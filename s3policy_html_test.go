@@ -0,0 +1,115 @@
+package s3Presign
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestRenderFormHtml(t *testing.T) {
+	s3PolicyBase := getTestPolicy()
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	output := &bytes.Buffer{}
+	err = RenderFormHtml(output, formsData, FormOptions{
+		Title:     "Upload <avatar>",
+		Accept:    "image/png,image/jpeg",
+		CSRFToken: `token"1`,
+	})
+	if err != nil {
+		t.Fatalf("failed to render form: %s", err.Error())
+	}
+
+	html := output.String()
+	for _, expected := range []string{
+		`<h1>Upload &lt;avatar&gt;</h1>`,
+		`<meta name="csrf-token" content="token&#34;1" />`,
+		`<input type="file"   name="file" accept="image/png,image/jpeg" />`,
+		`value="Upload to Amazon S3"`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("form should contain %s:\n%s", expected, html)
+		}
+	}
+
+	// S3 ignore the fields after the file
+	fileIndex := strings.Index(html, `type="file"`)
+	for _, formData := range formsData.FormData {
+		if index := strings.Index(html, `name="`+formData.FormName+`"`); index < 0 || index > fileIndex {
+			t.Errorf("field [%s] should be before the file", formData.FormName)
+		}
+	}
+
+	// GenerateFormHtml is the same as the default template without options
+	generated, err := GenerateFormHtml(formsData)
+	if err != nil {
+		t.Fatalf("failed to generate form: %s", err.Error())
+	}
+
+	if !strings.Contains(generated, "<h1>AWS S3 File Uploader</h1>") || strings.Contains(generated, "csrf-token") {
+		t.Errorf("default form should use default labels:\n%s", generated)
+	}
+}
+
+func TestFormTemplate(t *testing.T) {
+	formsData := Forms{
+		Url:      "https://sigv4examplebucket.s3.amazonaws.com/",
+		FormData: []FormData{{FormName: "key", FormValue: "user/test.jpeg"}},
+	}
+
+	formTemplate, err := NewFormTemplate(`
+		{{ define "header" }}<h2>{{ index .Data "heading" }}</h2>{{ end }}
+		{{ define "submit" }}<button type="submit">{{ .SubmitLabel }}</button><script nonce="{{ .Nonce }}">init()</script>{{ end }}`)
+	if err != nil {
+		t.Fatalf("failed to create form template: %s", err.Error())
+	}
+
+	output := &bytes.Buffer{}
+	err = formTemplate.Render(output, formsData, FormOptions{
+		SubmitLabel: "Send",
+		Nonce:       "abc123",
+		Data:        map[string]interface{}{"heading": "Avatar"},
+	})
+	if err != nil {
+		t.Fatalf("failed to render form: %s", err.Error())
+	}
+
+	html := output.String()
+	for _, expected := range []string{
+		`<h2>Avatar</h2>`,
+		`<button type="submit">Send</button><script nonce="abc123">init()</script>`,
+		`<input type="hidden" name="key" value="user/test.jpeg" />`,
+		`<h3>File</h3>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("form should contain %s:\n%s", expected, html)
+		}
+	}
+
+	// the default template is not changed by the blocks
+	output.Reset()
+	_ = RenderFormHtml(output, formsData, FormOptions{})
+	if strings.Contains(output.String(), "<h2>") {
+		t.Errorf("default form should not use the custom blocks")
+	}
+
+	if _, err = NewFormTemplate(`{{ define "header" }}{{ end`); err == nil {
+		t.Errorf("invalid blocks should return error")
+	}
+
+	customTemplate := template.Must(template.New("custom").Parse(
+		`<form action="{{ .Url }}">{{ range .FormData }}<input name="{{ .FormName }}" value="{{ .FormValue }}">{{ end }}<input type="file" name="{{ .FileField }}"></form>`))
+	output.Reset()
+	if err = NewFormTemplateFrom(customTemplate).Render(output, formsData, FormOptions{}); err != nil {
+		t.Fatalf("failed to render custom template: %s", err.Error())
+	}
+
+	expected := `<form action="https://sigv4examplebucket.s3.amazonaws.com/"><input name="key" value="user/test.jpeg"><input type="file" name="file"></form>`
+	if output.String() != expected {
+		t.Errorf("custom form should be %s not %s", expected, output.String())
+	}
+}
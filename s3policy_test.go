@@ -2,7 +2,10 @@ package s3Presign
 
 import (
 	"fmt"
+	"net/http"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -126,6 +129,16 @@ func TestS3Signature(t *testing.T) {
 	if signature != signatureValue {
 		t.Log("signature is not correct")
 	}
+
+	// the cached signing key doesn't keep the secret key, and is derived again with the new secret key
+	if strings.Contains(s3PolicyBase.signingKeys.scope, defaultData.AwsConfig.AwsSecretKey) {
+		t.Errorf("signing key cache should not contain the secret key")
+	}
+
+	s3PolicyBase.AwsConfig.AwsSecretKey = "rotated"
+	if s3PolicyBase.generateSignature(encodedPolicy) == signature {
+		t.Errorf("signature should use the rotated secret key")
+	}
 }
 
 func TestGenerateHtml(t *testing.T) {
@@ -140,7 +153,8 @@ func TestGenerateHtml(t *testing.T) {
 		"Content-Type":                 "image/jpeg",
 		"Content-Disposition":          "Attachment; filename=test.jpeg",
 		"Content-Encoding":             "token",
-		"Expires":                      "Wed, 30 Dec 2015 12:00:00 UTC",
+		"Content-Language":             "en-US",
+		"Expires":                      "Wed, 30 Dec 2015 12:00:00 GMT",
 		"success_action_redirect":      "https://sigv4examplebucket.s3.amazonaws.com/successful_upload.html",
 		"success_action_status":        "204",
 		"x-amz-server-side-encryption": "AES256",
@@ -161,8 +175,8 @@ func TestGenerateHtml(t *testing.T) {
 	// s3PolicyBase.setXAmzDatePolicy()
 	// s3PolicyBase.setXAmzCredentialPolicy()
 	// s3PolicyBase.setXAmzAlgorithmPolicy()
-	// policy (generated with s3PolicyBase.GeneratePolicy())
-	// x-amz-signature (generated with s3PolicyBase.GeneratePolicy())
+	// policy (generated with s3PolicyBase.GeneratePolicyWithError())
+	// x-amz-signature (generated with s3PolicyBase.GeneratePolicyWithError())
 
 	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, defaultData.Acl)
 	s3PolicyBase.SetContentLengthPolicy(defaultData.StartRange, defaultData.StopRange)
@@ -176,6 +190,7 @@ func TestGenerateHtml(t *testing.T) {
 	s3PolicyBase.SetContentTypePolicy(ConditionMatchingExactMatch, defaultData.ContentType)               // https://www.rfc-editor.org/rfc/rfc9110.html#name-content-type
 	s3PolicyBase.SetContentDispositionPolicy(ConditionMatchingExactMatch, defaultData.ContentDisposition) // https://www.rfc-editor.org/rfc/rfc6266#section-4
 	s3PolicyBase.SetContentEncodingPolicy(ConditionMatchingExactMatch, defaultData.ContentEncoding)       // https://www.rfc-editor.org/rfc/rfc9110.html#field.content-encoding
+	s3PolicyBase.SetContentLanguagePolicy(ConditionMatchingExactMatch, "en-US")                           // https://www.rfc-editor.org/rfc/rfc9110.html#field.content-language
 	s3PolicyBase.SetExpiresPolicy(defaultData.TimeExpired)                                                // https://www.rfc-editor.org/rfc/rfc7234#section-5.3
 
 	// custom key
@@ -185,7 +200,11 @@ func TestGenerateHtml(t *testing.T) {
 	// amazon key
 	s3PolicyBase.SetXAmz("x-amz-server-side-encryption", ConditionMatchingExactMatch, defaultData.SSE)
 
-	_, _, formsData := s3PolicyBase.GeneratePolicy()
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
 	for _, value := range formsData.FormData {
 		if value.FormName == "policy" || value.FormName == "x-amz-signature" {
 			continue // don't need to check
@@ -207,17 +226,67 @@ func TestGenerateHtml(t *testing.T) {
 	generateHtml(formsData)
 }
 
+func TestXAmzMeta(t *testing.T) {
+	defaultData := getDefaultData()
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetXAmzMeta("invalid name", ConditionMatchingExactMatch, "value")
+	if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("invalid x-amz-meta name should return error")
+	}
+
+	s3PolicyBase = NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetXAmzMeta("description", ConditionMatchingExactMatch, strings.Repeat("a", MaxXAmzMetaSize))
+	if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("x-amz-meta size more than %d bytes should return error", MaxXAmzMetaSize)
+	}
+
+	s3PolicyBase = NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetXAmzMeta("title", ConditionMatchingExactMatch, "café")
+	if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("non-ASCII x-amz-meta value without encoding should return error")
+	}
+
+	s3PolicyBase.SetXAmzMetaEncoding(true)
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	for _, value := range formsData.FormData {
+		if value.FormName != "x-amz-meta-title" {
+			continue
+		}
+
+		header := http.Header{}
+		header.Set(value.FormName, value.FormValue)
+		xAmzMeta, err := DecodeXAmzMeta(header)
+		if err != nil {
+			t.Fatalf("failed to decode x-amz-meta: %s", err.Error())
+		}
+
+		if xAmzMeta["title"] != "café" {
+			t.Errorf("decoded value [%s] should be [café]", xAmzMeta["title"])
+		}
+
+		return
+	}
+
+	t.Errorf("x-amz-meta-title not found in form data")
+}
+
 func generateHtml(formsData Forms) {
 	htmlDocument, err := GenerateFormHtml(formsData)
 	if err != nil {
 		panic(err.Error())
-		return
 	}
 
 	fileCreate, err := os.Create(fmt.Sprintf("%s%s", htmlTestLocation, htmlTestFileName))
 	if err != nil {
 		panic(err.Error())
-		return
 	}
 
 	defer fileCreate.Close()
@@ -226,6 +295,51 @@ func generateHtml(formsData Forms) {
 
 	if err != nil {
 		panic(err.Error())
-		return
+	}
+}
+
+func TestSecurityTokenDevPay(t *testing.T) {
+	defaultData := getDefaultData()
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	s3PolicyBase.SetXAmzSecurityTokenPolicy(ConditionMatchingExactMatch, defaultData.UserToken, defaultData.ProductToken)
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if !hasFormValue(formsData, "x-amz-security-token", defaultData.UserToken+","+defaultData.ProductToken) {
+		t.Errorf("DevPay token should be added as x-amz-security-token: %+v", formsData.FormData)
+	}
+
+	// the session token would be dropped by the DevPay token
+	s3PolicyBase.AwsConfig.AwsSessionToken = "session-token"
+	if _, _, _, err = s3PolicyBase.GeneratePolicyWithError(); err == nil {
+		t.Errorf("DevPay token with session token should return error")
+	}
+}
+
+func TestGeneratePolicyDeprecated(t *testing.T) {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig, WithClock(clock))
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, defaultData.Key)
+	expectedPolicy, expectedSignature, expectedForms, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	policy, signature, forms := s3PolicyBase.GeneratePolicy()
+	if policy != expectedPolicy || signature != expectedSignature || !reflect.DeepEqual(forms, expectedForms) {
+		t.Errorf("GeneratePolicy should return the same values as GeneratePolicyWithError")
+	}
+
+	s3PolicyBase.AddCondition("key", ConditionMatchingExactMatch, "other")
+	if policy, signature, forms = s3PolicyBase.GeneratePolicy(); policy != "" || signature != "" || len(forms.FormData) != 0 {
+		t.Errorf("GeneratePolicy should return empty values for invalid policy")
 	}
 }
@@ -0,0 +1,287 @@
+package s3Presign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnsignedPayload payload hash used when the request body is not signed, S3 only
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// EmptyPayloadHash SHA-256 hash of the empty body
+const EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// MaxPresignExpiresIn maximum expiration of the presigned URL
+const MaxPresignExpiresIn = 7 * 24 * time.Hour
+
+// headers that can be changed by proxies or the HTTP client, they're not signed
+var unsignedHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+}
+
+// RequestSigner sign HTTP requests with SigV4 header authentication, used for the S3 REST API (ex: HeadObject, DeleteObject, ListObjectsV2).
+// See https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html, for more details
+type RequestSigner struct {
+	AwsConfig  AwsConfig
+	AwsService string // default s3
+	Clock      Clock  // default SystemClock
+}
+
+// NewRequestSigner create the request signer for S3
+func NewRequestSigner(config AwsConfig) *RequestSigner {
+	return &RequestSigner{
+		AwsConfig:  config,
+		AwsService: "s3",
+		Clock:      SystemClock,
+	}
+}
+
+// RequestSigner create the request signer with the policy credentials, service and clock
+func (base *BaseS3Policy) RequestSigner() *RequestSigner {
+	return &RequestSigner{
+		AwsConfig:  base.AwsConfig,
+		AwsService: base.AwsService,
+		Clock:      base.Clock,
+	}
+}
+
+// Sign add the X-Amz-Date, X-Amz-Security-Token (for temporary credentials) and Authorization headers to the request.
+// payloadHash is the hex SHA-256 hash of the body or UnsignedPayload, the hash is calculated from the body if it's empty.
+// For s3 the X-Amz-Content-Sha256 header is added, and the path is not normalized because the object key is used as it is.
+// All the request headers are signed except Authorization, User-Agent, X-Amzn-Trace-Id and Expect,
+// so the headers must not be changed after signing.
+func (signer *RequestSigner) Sign(request *http.Request, payloadHash string) error {
+	config := signer.AwsConfig
+	if config.AwsAccessKey == "" || config.AwsSecretKey == "" || config.AwsRegion == "" {
+		return fmt.Errorf("access key, secret key and region are required to sign the request")
+	}
+
+	if payloadHash == "" {
+		var err error
+		if payloadHash, err = getPayloadHash(request); err != nil {
+			return err
+		}
+	}
+
+	now := signer.getClock().Now().UTC()
+	service := signer.getService()
+	request.Header.Del("Authorization")
+	request.Header.Set("X-Amz-Date", now.Format(AmzDateFormat))
+	if config.AwsSessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", config.AwsSessionToken)
+	}
+
+	if service == "s3" {
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalHeaders, signedHeaders := getCanonicalHeaders(request)
+	scope, signature := signer.getSignature(now, request, canonicalHeaders, signedHeaders, payloadHash)
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		AmzAlgorithm, config.AwsAccessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+// Presign get the presigned URL of the request with SigV4 query string authentication, valid for expiresIn (max 7 days).
+// Only the host is signed and the payload is UnsignedPayload, so the URL can be used by clients that can't sign (ex: browser).
+func (signer *RequestSigner) Presign(request *http.Request, expiresIn time.Duration) (string, error) {
+	config := signer.AwsConfig
+	if config.AwsAccessKey == "" || config.AwsSecretKey == "" || config.AwsRegion == "" {
+		return "", fmt.Errorf("access key, secret key and region are required to presign the request")
+	}
+
+	if expiresIn < time.Second || expiresIn > MaxPresignExpiresIn {
+		return "", fmt.Errorf("presigned URL expiration must be between 1 second and %s, got %s", MaxPresignExpiresIn, expiresIn)
+	}
+
+	now := signer.getClock().Now().UTC()
+	signingDate := now.Format(SignatureDateFormat)
+	scope := strings.Join([]string{signingDate, config.AwsRegion, signer.getService(), "aws4_request"}, "/")
+
+	presignedUrl := *request.URL
+	query := presignedUrl.Query()
+	query.Set("X-Amz-Algorithm", AmzAlgorithm)
+	query.Set("X-Amz-Credential", config.AwsAccessKey+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(AmzDateFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expiresIn/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if config.AwsSessionToken != "" {
+		query.Set("X-Amz-Security-Token", config.AwsSessionToken)
+	}
+
+	presignedUrl.RawQuery = query.Encode()
+	presignedRequest := &http.Request{Method: request.Method, URL: &presignedUrl, Host: request.Host, Header: http.Header{}}
+	canonicalHeaders, signedHeaders := getCanonicalHeaders(presignedRequest)
+	_, signature := signer.getSignature(now, presignedRequest, canonicalHeaders, signedHeaders, UnsignedPayload)
+
+	// the URL query is the canonical query, so it's not encoded differently by the client
+	presignedUrl.RawQuery = getCanonicalQuery(presignedRequest) + "&X-Amz-Signature=" + signature
+	return presignedUrl.String(), nil
+}
+
+func (signer *RequestSigner) getService() string {
+	if signer.AwsService == "" {
+		return "s3"
+	}
+
+	return signer.AwsService
+}
+
+func (signer *RequestSigner) getClock() Clock {
+	if signer.Clock == nil {
+		return SystemClock
+	}
+
+	return signer.Clock
+}
+
+// get the credential scope and the signature of the canonical request
+func (signer *RequestSigner) getSignature(now time.Time, request *http.Request, canonicalHeaders, signedHeaders, payloadHash string) (scope, signature string) {
+	service := signer.getService()
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		getCanonicalPath(request, service != "s3"),
+		getCanonicalQuery(request),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	signingDate := now.Format(SignatureDateFormat)
+	scope = strings.Join([]string{signingDate, signer.AwsConfig.AwsRegion, service, "aws4_request"}, "/")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{AmzAlgorithm, now.Format(AmzDateFormat), scope, hex.EncodeToString(canonicalHash[:])}, "\n")
+
+	signingKey := deriveSigningKey(signer.AwsConfig.AwsSecretKey, signingDate, signer.AwsConfig.AwsRegion, service)
+	return scope, hex.EncodeToString(makeHmac(signingKey, []byte(stringToSign)))
+}
+
+// get the body hash, the body is read and replaced so it can still be sent
+func getPayloadHash(request *http.Request) (string, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return EmptyPayloadHash, nil
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	_ = request.Body.Close()
+	request.Body = io.NopCloser(bytes.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// get the URI-encoded path, the dot segments and the duplicate slashes are removed if normalize
+func getCanonicalPath(request *http.Request, normalize bool) string {
+	requestPath := request.URL.Path
+	if requestPath == "" {
+		return "/"
+	}
+
+	if normalize {
+		cleanPath := path.Clean(requestPath)
+		if strings.HasSuffix(requestPath, "/") && cleanPath != "/" {
+			cleanPath += "/"
+		}
+
+		requestPath = cleanPath
+	}
+
+	return uriEncode(requestPath, false)
+}
+
+// get the query parameters sorted by name and value, names and values are URI-encoded
+func getCanonicalQuery(request *http.Request) string {
+	var parameters [][2]string
+	for name, values := range request.URL.Query() {
+		for _, value := range values {
+			parameters = append(parameters, [2]string{uriEncode(name, true), uriEncode(value, true)})
+		}
+	}
+
+	// sorted by the encoded name, then by the encoded value
+	sort.Slice(parameters, func(i, j int) bool {
+		if parameters[i][0] != parameters[j][0] {
+			return parameters[i][0] < parameters[j][0]
+		}
+
+		return parameters[i][1] < parameters[j][1]
+	})
+
+	encoded := make([]string, 0, len(parameters))
+	for _, parameter := range parameters {
+		encoded = append(encoded, parameter[0]+"="+parameter[1])
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+// get the canonical headers with the host, the names are lowercase and sorted,
+// the values are trimmed with the sequential spaces replaced by single space
+func getCanonicalHeaders(request *http.Request) (canonicalHeaders, signedHeaders string) {
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+
+	headers := map[string][]string{"host": {host}}
+	for name, values := range request.Header {
+		lowerName := strings.ToLower(name)
+		if unsignedHeaders[lowerName] || lowerName == "host" {
+			continue
+		}
+
+		for _, value := range values {
+			headers[lowerName] = append(headers[lowerName], strings.Join(strings.Fields(value), " "))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		builder.WriteString(name + ":" + strings.Join(headers[name], ",") + "\n")
+	}
+
+	return builder.String(), strings.Join(names, ";")
+}
+
+// URI-encode every byte except the unreserved characters (A-Z, a-z, 0-9, '-', '.', '_', '~'), '/' is encoded if encodeSlash
+func uriEncode(value string, encodeSlash bool) string {
+	var builder strings.Builder
+	for idx := 0; idx < len(value); idx++ {
+		char := value[idx]
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9',
+			char == '-', char == '.', char == '_', char == '~', char == '/' && !encodeSlash:
+			builder.WriteByte(char)
+		default:
+			builder.WriteString(fmt.Sprintf("%%%02X", char))
+		}
+	}
+
+	return builder.String()
+}
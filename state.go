@@ -0,0 +1,318 @@
+package s3Presign
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PolicyStateVersion version of PolicyState written by State
+const PolicyStateVersion = 1
+
+// PolicyState the configured but unsigned policy, used to store the policy and sign it later with NewS3PolicyFromState.
+// It never has the credentials, the session token and the conditions generated on signing.
+// Clock, LintRules and AwsConfig.Guardrails are not stored, they're set by the node that sign the policy.
+//
+// The format is JSON only (version 1), durations use time.Duration format:
+//
+//	{
+//	  "version": 1,
+//	  "service": "s3",
+//	  "endpoint": "https://sigv4examplebucket.s3.us-east-1.amazonaws.com/",
+//	  "bucket": "sigv4examplebucket",
+//	  "expiresIn": "10m0s",
+//	  "expiration": "2015-12-30T12:00:00Z",
+//	  "minTTL": "1m0s",
+//	  "maxTTL": "1h0m0s",
+//	  "credentialExpiryMode": "clamp",
+//	  "xAmzMetaEncoding": true,
+//	  "contentTypeFromKey": true,
+//	  "strictLint": true,
+//	  "allowedContentTypes": ["image/png", "image/jpeg"],
+//	  "fields": [{"field": "key", "condition": "starts-with", "value": "user/"}, {"field": "content-length-range", "condition": "content-length-range", "min": 1, "max": 1048576}],
+//	  "conditions": [{"field": "x-ignore-tracking", "condition": "eq", "value": "1"}]
+//	}
+//
+// "expiration" is only used when "expiresIn" is empty. "fields" are the conditions set with the Set* functions,
+// "conditions" are the conditions added with AddCondition and AddRange.
+type PolicyState struct {
+	Version              int              `json:"version"`
+	Service              string           `json:"service,omitempty"`
+	Endpoint             string           `json:"endpoint,omitempty"`
+	Bucket               string           `json:"bucket,omitempty"`
+	ExpiresIn            string           `json:"expiresIn,omitempty"`
+	Expiration           *time.Time       `json:"expiration,omitempty"`
+	MinTTL               string           `json:"minTTL,omitempty"`
+	MaxTTL               string           `json:"maxTTL,omitempty"`
+	CredentialExpiryMode string           `json:"credentialExpiryMode,omitempty"`
+	XAmzMetaEncoding     bool             `json:"xAmzMetaEncoding,omitempty"`
+	ContentTypeFromKey   bool             `json:"contentTypeFromKey,omitempty"`
+	StrictLint           bool             `json:"strictLint,omitempty"`
+	AllowedContentTypes  []string         `json:"allowedContentTypes,omitempty"`
+	Fields               []ConditionState `json:"fields,omitempty"`
+	Conditions           []ConditionState `json:"conditions,omitempty"`
+}
+
+type ConditionState struct {
+	Field     string `json:"field"`
+	Condition string `json:"condition"`
+	Value     string `json:"value,omitempty"`
+	Min       uint64 `json:"min,omitempty"`
+	Max       uint64 `json:"max,omitempty"`
+}
+
+// State get the policy state to store, return error if the policy has secret material
+// (session token condition, or condition value equal to the secret key or the session token)
+// or custom lint rules that can't be stored.
+func (base *BaseS3Policy) State() (PolicyState, error) {
+	if len(base.LintRules) > 0 {
+		return PolicyState{}, fmt.Errorf("custom lint rules can't be stored")
+	}
+
+	state := PolicyState{
+		Version:              PolicyStateVersion,
+		Service:              base.AwsService,
+		Endpoint:             base.Endpoint,
+		Bucket:               base.AwsConfig.AwsBucket,
+		MinTTL:               formatStateDuration(base.MinTTL),
+		MaxTTL:               formatStateDuration(base.MaxTTL),
+		CredentialExpiryMode: base.CredentialExpiryMode,
+		XAmzMetaEncoding:     base.XAmzMetaEncoding,
+		ContentTypeFromKey:   base.ContentTypeFromKey,
+		StrictLint:           base.StrictLint,
+	}
+
+	if base.ExpiresIn > 0 {
+		state.ExpiresIn = base.ExpiresIn.String()
+	} else if !base.ExpiredDate.IsZero() {
+		expiration := base.ExpiredDate.UTC()
+		state.Expiration = &expiration
+	}
+
+	if base.Policy == nil {
+		return state, nil
+	}
+
+	state.AllowedContentTypes = append([]string(nil), base.Policy.AllowedContentTypes...)
+
+	var fields []Condition
+	for _, field := range base.Policy.getFields() {
+		if field.condition.ConditionUsed != "" {
+			fields = append(fields, newCondition(field.name, *field.condition))
+		}
+	}
+
+	for _, policyConditions := range []map[string]PolicyConditions{base.Policy.XAmzMeta, base.Policy.XAmz} {
+		names := make([]string, 0, len(policyConditions))
+		for name := range policyConditions {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		for _, name := range names {
+			fields = append(fields, newCondition(name, policyConditions[name]))
+		}
+	}
+
+	var err error
+	if state.Fields, err = base.getConditionStates(fields); err != nil {
+		return PolicyState{}, err
+	}
+
+	if state.Conditions, err = base.getConditionStates(base.Policy.Conditions); err != nil {
+		return PolicyState{}, err
+	}
+
+	return state, nil
+}
+
+// MarshalJSON encode the policy as PolicyState, so the credentials are never encoded.
+// It has value receiver so the policy encoded by value or embedded by value is encoded the same.
+func (base BaseS3Policy) MarshalJSON() ([]byte, error) {
+	state, err := base.State()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(state)
+}
+
+// UnmarshalJSON restore the policy from PolicyState with NewS3PolicyFromState,
+// signed with the credentials and the clock of the policy, ex: json.Unmarshal(data, NewS3Policy(awsConfig))
+func (base *BaseS3Policy) UnmarshalJSON(data []byte) error {
+	var state PolicyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	var options []Option
+	if base.Clock != nil {
+		options = append(options, WithClock(base.Clock))
+	}
+
+	restored, err := NewS3PolicyFromState(base.AwsConfig, state, options...)
+	if err != nil {
+		return err
+	}
+
+	*base = *restored
+	return nil
+}
+
+// NewS3PolicyFromState create the policy from the stored state, signed with the config credentials.
+// The state bucket is used instead of config.AwsBucket if it's set, the options are applied after the state.
+func NewS3PolicyFromState(config AwsConfig, state PolicyState, options ...Option) (base *BaseS3Policy, err error) {
+	if state.Version != PolicyStateVersion {
+		return nil, fmt.Errorf("policy state version %d is not supported", state.Version)
+	}
+
+	if state.Bucket != "" {
+		config.AwsBucket = state.Bucket
+	}
+
+	base = NewS3Policy(config)
+
+	// the setters panic on invalid condition, return it as error
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			base, err = nil, fmt.Errorf("invalid policy state: %v", recovered)
+		}
+	}()
+
+	if state.Service != "" {
+		base.AwsService = state.Service
+	}
+
+	base.Endpoint = state.Endpoint
+	base.XAmzMetaEncoding = state.XAmzMetaEncoding
+	base.ContentTypeFromKey = state.ContentTypeFromKey
+	base.StrictLint = state.StrictLint
+	if state.CredentialExpiryMode != "" {
+		base.SetCredentialExpiryMode(state.CredentialExpiryMode)
+	}
+
+	var durations [3]time.Duration
+	for idx, value := range []string{state.ExpiresIn, state.MinTTL, state.MaxTTL} {
+		if value == "" {
+			continue
+		}
+
+		if durations[idx], err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid policy state duration [%s]: %w", value, err)
+		}
+	}
+
+	base.SetTTLBounds(durations[1], durations[2])
+	if durations[0] > 0 {
+		base.SetExpiresIn(durations[0])
+	} else if state.Expiration != nil {
+		base.SetExpirationDate(*state.Expiration)
+	}
+
+	// the Content-Type condition is stored in the fields
+	if len(state.AllowedContentTypes) > 0 {
+		base.Policy.AllowedContentTypes = append([]string(nil), state.AllowedContentTypes...)
+	}
+
+	for _, field := range state.Fields {
+		base.setConditionState(field)
+	}
+
+	for _, condition := range state.Conditions {
+		if condition.Condition == ConditionSpecifyingRange {
+			base.AddRange(condition.Field, condition.Min, condition.Max)
+		} else {
+			base.AddCondition(condition.Field, condition.Condition, condition.Value)
+		}
+	}
+
+	for _, option := range options {
+		option(base)
+	}
+
+	return base, nil
+}
+
+// get the condition states, return error if the condition has secret material.
+// The conditions generated on signing are not stored.
+func (base *BaseS3Policy) getConditionStates(conditions []Condition) ([]ConditionState, error) {
+	var states []ConditionState
+	for _, condition := range conditions {
+		if isSigningCondition(condition.Field) {
+			continue
+		}
+
+		if strings.EqualFold(condition.Field, "x-amz-security-token") || base.isSecretValue(condition.PolicyValue) {
+			return nil, fmt.Errorf("condition [%s] has secret material, it can't be stored", condition.Field)
+		}
+
+		states = append(states, ConditionState{
+			Field:     condition.Field,
+			Condition: condition.ConditionUsed,
+			Value:     condition.PolicyValue,
+			Min:       condition.PolicyStartRange,
+			Max:       condition.PolicyStopRange,
+		})
+	}
+
+	return states, nil
+}
+
+func (base *BaseS3Policy) isSecretValue(value string) bool {
+	for _, secret := range []string{base.AwsConfig.AwsSecretKey, base.AwsConfig.AwsSessionToken} {
+		if secret != "" && strings.Contains(value, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// set the condition to the policy field, x-amz-meta-* or x-amz-* conditions
+func (base *BaseS3Policy) setConditionState(state ConditionState) {
+	condition := PolicyConditions{
+		ConditionUsed:    state.Condition,
+		PolicyValue:      state.Value,
+		PolicyStartRange: state.Min,
+		PolicyStopRange:  state.Max,
+	}
+
+	if state.Condition == ConditionSpecifyingRange {
+		if state.Field != ConditionSpecifyingRange {
+			panic(fmt.Sprintf("field [%s] can't use content-length-range", state.Field))
+		}
+
+		base.SetContentLengthPolicy(state.Min, state.Max)
+		return
+	}
+
+	if !checkConditions(getFieldConditions(state.Field), state.Condition) {
+		panic(fmt.Sprintf("condition matching type [%s] can't be used for field [%s]", state.Condition, state.Field))
+	}
+
+	for _, field := range base.Policy.getFields() {
+		if field.name == state.Field {
+			*field.condition = condition
+			return
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(state.Field, XAmzMetaKey):
+		base.SetXAmzMeta(state.Field, state.Condition, state.Value)
+	case strings.HasPrefix(state.Field, XAmzKey):
+		base.SetXAmz(state.Field, state.Condition, state.Value)
+	default:
+		panic(fmt.Sprintf("field [%s] not found", state.Field))
+	}
+}
+
+func formatStateDuration(duration time.Duration) string {
+	if duration == 0 {
+		return ""
+	}
+
+	return duration.String()
+}
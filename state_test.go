@@ -0,0 +1,236 @@
+package s3Presign
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getStatePolicy() *BaseS3Policy {
+	defaultData := getDefaultData()
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig,
+		WithService("s3"),
+		WithEndpoint("https://sigv4examplebucket.s3.us-east-1.amazonaws.com/"),
+		WithExpiry(15*time.Minute),
+		WithTTLBounds(time.Minute, time.Hour),
+	)
+
+	s3PolicyBase.SetCredentialExpiryMode(CredentialExpiryError)
+	s3PolicyBase.SetXAmzMetaEncoding(true)
+	s3PolicyBase.SetContentTypeFromKey(true)
+	s3PolicyBase.SetStrictLint(true)
+	s3PolicyBase.AllowContentTypes([]string{"image/png", "image/jpeg"})
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingStartWith, "user/user1/")
+	s3PolicyBase.SetContentLengthPolicy(1, 1048576)
+	s3PolicyBase.SetAclPolicy(ConditionMatchingExactMatch, "private")
+	s3PolicyBase.SetXAmzMeta("title", ConditionMatchingExactMatch, "café")
+	s3PolicyBase.SetXAmzMeta("tag", ConditionMatchingStartWith, "")
+	s3PolicyBase.SetXAmz("x-amz-server-side-encryption", ConditionMatchingExactMatch, "AES256")
+	s3PolicyBase.AddCondition("x-ignore-tracking", ConditionMatchingExactMatch, "1")
+	s3PolicyBase.AddRange(ConditionSpecifyingRange, 10, 2048)
+	return s3PolicyBase
+}
+
+func TestPolicyStateRoundTrip(t *testing.T) {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	s3PolicyBase := getStatePolicy()
+	s3PolicyBase.Clock = clock
+
+	// generated once, so the stored state must not have the signing conditions
+	expectedPolicy, expectedSignature, _, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	output, err := json.Marshal(s3PolicyBase)
+	if err != nil {
+		t.Fatalf("failed to encode policy: %s", err.Error())
+	}
+
+	for _, secret := range []string{defaultData.AwsConfig.AwsSecretKey, defaultData.AwsConfig.AwsAccessKey, "x-amz-date", "x-amz-credential"} {
+		if strings.Contains(string(output), secret) {
+			t.Errorf("stored policy should not contain %s: %s", secret, string(output))
+		}
+	}
+
+	var state PolicyState
+	if err = json.Unmarshal(output, &state); err != nil {
+		t.Fatalf("failed to decode state: %s", err.Error())
+	}
+
+	if state.Version != PolicyStateVersion || state.ExpiresIn != "15m0s" || state.MinTTL != "1m0s" || state.MaxTTL != "1h0m0s" {
+		t.Errorf("invalid stored state: %s", string(output))
+	}
+
+	// signed on another node with its own credentials
+	restored, err := NewS3PolicyFromState(AwsConfig{
+		AwsAccessKey: defaultData.AwsConfig.AwsAccessKey,
+		AwsSecretKey: defaultData.AwsConfig.AwsSecretKey,
+		AwsRegion:    defaultData.AwsConfig.AwsRegion,
+	}, state, WithClock(clock))
+	if err != nil {
+		t.Fatalf("failed to restore policy: %s", err.Error())
+	}
+
+	restoredState, err := restored.State()
+	if err != nil {
+		t.Fatalf("failed to get restored state: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(restoredState, state) {
+		t.Errorf("restored state should be %+v not %+v", state, restoredState)
+	}
+
+	encodedPolicy, signature, _, err := restored.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate restored policy: %s", err.Error())
+	}
+
+	if encodedPolicy != expectedPolicy || signature != expectedSignature {
+		t.Errorf("restored policy should generate the same policy and signature")
+	}
+}
+
+func TestPolicyStateByValue(t *testing.T) {
+	defaultData := getDefaultData()
+	clock := ClockFunc(func() time.Time {
+		return defaultData.DateCreated
+	})
+
+	s3PolicyBase := getStatePolicy()
+	s3PolicyBase.Clock = clock
+	expected, err := json.Marshal(s3PolicyBase)
+	if err != nil {
+		t.Fatalf("failed to encode policy: %s", err.Error())
+	}
+
+	// encoded by value and embedded by value use the state too
+	embedded := struct {
+		BaseS3Policy
+	}{BaseS3Policy: *s3PolicyBase}
+	for name, value := range map[string]interface{}{
+		"value":    *s3PolicyBase,
+		"embedded": embedded,
+		"field":    map[string]BaseS3Policy{"policy": *s3PolicyBase},
+	} {
+		output, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("failed to encode policy by %s: %s", name, err.Error())
+		}
+
+		if strings.Contains(string(output), defaultData.AwsConfig.AwsSecretKey) || !strings.Contains(string(output), string(expected)) {
+			t.Errorf("policy encoded by %s should be the state %s not %s", name, string(expected), string(output))
+		}
+	}
+
+	// decoded with the credentials and the clock of the policy
+	restored := NewS3Policy(defaultData.AwsConfig, WithClock(clock))
+	if err = json.Unmarshal(expected, restored); err != nil {
+		t.Fatalf("failed to decode policy: %s", err.Error())
+	}
+
+	expectedPolicy, expectedSignature, _, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	encodedPolicy, signature, _, err := restored.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate decoded policy: %s", err.Error())
+	}
+
+	if encodedPolicy != expectedPolicy || signature != expectedSignature || restored.AwsConfig.AwsSecretKey != defaultData.AwsConfig.AwsSecretKey {
+		t.Errorf("decoded policy should generate the same policy and signature")
+	}
+
+	if err = json.Unmarshal([]byte(`{"version":0}`), restored); err == nil {
+		t.Errorf("unsupported state version should return error")
+	}
+}
+
+func TestPolicyStateExpiration(t *testing.T) {
+	defaultData := getDefaultData()
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetExpirationDate(defaultData.TimeExpired)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "test.txt")
+
+	state, err := s3PolicyBase.State()
+	if err != nil {
+		t.Fatalf("failed to get state: %s", err.Error())
+	}
+
+	if state.ExpiresIn != "" || state.Expiration == nil || !state.Expiration.Equal(defaultData.TimeExpired) {
+		t.Errorf("state should have the absolute expiration: %+v", state)
+	}
+
+	restored, err := NewS3PolicyFromState(defaultData.AwsConfig, state)
+	if err != nil {
+		t.Fatalf("failed to restore policy: %s", err.Error())
+	}
+
+	if restored.ExpiresIn != 0 || !restored.ExpiredDate.Equal(defaultData.TimeExpired) {
+		t.Errorf("restored policy should expire at %s not %s", defaultData.TimeExpired, restored.ExpiredDate)
+	}
+}
+
+func TestPolicyStateSecret(t *testing.T) {
+	defaultData := getDefaultData()
+	defaultData.AwsConfig.AwsSessionToken = "session-token"
+
+	s3PolicyBase := NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetKeyPolicy(ConditionMatchingExactMatch, "test.txt")
+	if _, err := s3PolicyBase.State(); err != nil {
+		t.Fatalf("policy without secret should be stored: %s", err.Error())
+	}
+
+	// the session token condition is added to the generated policy only
+	if _, _, _, err := s3PolicyBase.GeneratePolicyWithError(); err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	if _, err := json.Marshal(s3PolicyBase); err != nil {
+		t.Errorf("generated policy should still be stored: %s", err.Error())
+	}
+
+	s3PolicyBase.SetXAmzSecurityTokenPolicy(ConditionMatchingExactMatch, "session-token", "product-token")
+	if _, err := json.Marshal(s3PolicyBase); err == nil {
+		t.Errorf("policy with session token should not be stored")
+	}
+
+	s3PolicyBase = NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetXAmzMeta("note", ConditionMatchingExactMatch, "key "+defaultData.AwsConfig.AwsSecretKey)
+	if _, err := s3PolicyBase.State(); err == nil {
+		t.Errorf("policy with secret key value should not be stored")
+	}
+
+	s3PolicyBase = NewS3Policy(defaultData.AwsConfig)
+	s3PolicyBase.SetStrictLint(true, LintMissingSizeCapRule())
+	if _, err := s3PolicyBase.State(); err == nil {
+		t.Errorf("policy with custom lint rules should not be stored")
+	}
+}
+
+func TestNewS3PolicyFromStateInvalid(t *testing.T) {
+	defaultData := getDefaultData()
+	invalidStates := []PolicyState{
+		{Version: 2},
+		{Version: PolicyStateVersion, ExpiresIn: "10 minutes"},
+		{Version: PolicyStateVersion, CredentialExpiryMode: "unknown"},
+		{Version: PolicyStateVersion, Fields: []ConditionState{{Field: "unknown", Condition: ConditionMatchingExactMatch}}},
+		{Version: PolicyStateVersion, Fields: []ConditionState{{Field: "acl", Condition: "in"}}},
+		{Version: PolicyStateVersion, Fields: []ConditionState{{Field: "key", Condition: ConditionSpecifyingRange}}},
+		{Version: PolicyStateVersion, Conditions: []ConditionState{{Field: "unknown", Condition: ConditionMatchingExactMatch}}},
+	}
+
+	for _, state := range invalidStates {
+		if _, err := NewS3PolicyFromState(defaultData.AwsConfig, state); err == nil {
+			t.Errorf("state %+v should return error", state)
+		}
+	}
+}
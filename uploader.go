@@ -0,0 +1,49 @@
+package s3Presign
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"strings"
+)
+
+//go:embed assets/uploader.html
+var uploaderBlocks string
+
+//go:embed assets/uploader.js
+var uploaderScript string
+
+//go:embed assets/uploader.css
+var uploaderStyle string
+
+// the default form with the uploader blocks, the form still works without JavaScript
+var uploaderTemplate = template.Must(template.Must(template.New("presign").
+	Funcs(template.FuncMap{"join": strings.Join}).
+	Parse(htmlDocuments)).
+	Parse(uploaderBlocks))
+
+// UploaderTemplateData the data executed by the uploader template
+type UploaderTemplateData struct {
+	FormTemplateData
+	Constraints UploadConstraints
+	Script      template.JS
+	Style       template.CSS
+}
+
+// RenderUploaderHtml write the upload form with drag-and-drop, file checks and upload progress.
+// The file is uploaded with XHR and the result is sent as "s3upload" event of the form,
+// use success_action_status 201 to get the uploaded key and location.
+// Without JavaScript the form is submitted as the default form.
+func RenderUploaderHtml(writer io.Writer, formData Forms, options FormOptions) error {
+	constraints := formData.Constraints()
+	if options.Accept == "" {
+		options.Accept = constraints.getAccept()
+	}
+
+	return uploaderTemplate.ExecuteTemplate(writer, "presign", UploaderTemplateData{
+		FormTemplateData: getFormTemplateData(formData, options),
+		Constraints:      constraints,
+		Script:           template.JS(uploaderScript),
+		Style:            template.CSS(uploaderStyle),
+	})
+}
@@ -0,0 +1,51 @@
+package s3Presign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderUploaderHtml(t *testing.T) {
+	s3PolicyBase := getTestPolicy()
+	s3PolicyBase.SetContentTypePolicy(ConditionMatchingStartWith, "image/")
+	_, _, formsData, err := s3PolicyBase.GeneratePolicyWithError()
+	if err != nil {
+		t.Fatalf("failed to generate policy: %s", err.Error())
+	}
+
+	output := &bytes.Buffer{}
+	if err = RenderUploaderHtml(output, formsData, FormOptions{Nonce: "abc123", DropLabel: "Drop <image>"}); err != nil {
+		t.Fatalf("failed to render uploader: %s", err.Error())
+	}
+
+	html := output.String()
+	for _, expected := range []string{
+		`<style nonce="abc123">.s3-uploader {`,
+		`<script nonce="abc123">// S3 uploader`,
+		`"use strict";`,
+		`data-min-size="0" data-max-size="10485760" data-content-type-prefix="image/"`,
+		`<input type="file" name="file" accept="image/*" />`,
+		`<p data-s3-drop-label>Drop &lt;image&gt;</p>`,
+		`<form action="https://sigv4examplebucket.s3.amazonaws.com/" method="post" enctype="multipart/form-data">`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("uploader should contain %s", expected)
+		}
+	}
+
+	// the fallback form must keep the file as the last field
+	fileIndex := strings.Index(html, `type="file"`)
+	for _, formData := range formsData.FormData {
+		if index := strings.Index(html, `name="`+formData.FormName+`"`); index < 0 || index > fileIndex {
+			t.Errorf("field [%s] should be before the file", formData.FormName)
+		}
+	}
+
+	// the default form is not changed by the uploader blocks
+	output.Reset()
+	_ = RenderFormHtml(output, formsData, FormOptions{})
+	if strings.Contains(output.String(), "data-s3-uploader") {
+		t.Errorf("default form should not use the uploader blocks")
+	}
+}
@@ -4,13 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"mime"
+	"net/http"
 	"strings"
+	"unicode/utf8"
 )
 
-type PolicyData interface {
-	PolicyConditions | map[string]PolicyConditions | map[string]interface{}
-}
-
 func getCustomKey(key, keyPolicy string) string {
 	if len(key) <= len(keyPolicy) { // check if the same length as keyPolicy (6 char)
 		keyPolicy = fmt.Sprintf("%s%s", keyPolicy, key)
@@ -26,101 +25,85 @@ func getCustomKey(key, keyPolicy string) string {
 	return keyPolicy
 }
 
-// check if condition matching is exists and can be used by the policy
-func checkConditions(policyConditions ConditionMatching, conditionMatch string) (canBeUsed bool) {
-	switch conditionMatch {
-	case ConditionMatchingStartWith, ConditionMatchingExactMatch, ConditionSpecifyingRange:
-		var policyCondition map[string]bool
-		conditionConfigMarshal, _ := json.Marshal(policyConditions)
-		_ = json.Unmarshal(conditionConfigMarshal, &policyCondition)
+// check if name only contains token characters allowed in a header field name (RFC 7230 section 3.2.6)
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
 
-		if _, ok := policyCondition[conditionMatch]; !ok {
+	for _, char := range name {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", char):
+		default:
 			return false
 		}
-
-		return true
-	default:
-		panic("Conditions matching not found!")
 	}
+
+	return true
 }
 
-func conditionFunc(elementName string, policyCondition PolicyConditions) (interface{}, bool) {
-	var isFormData, exists bool
-	if strings.Contains(elementName, XAmzMetaKey) {
-		isFormData, exists = validInputForm[XAmzMetaKey]
-	} else {
-		isFormData, exists = validInputForm[elementName]
+func isASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= utf8.RuneSelf {
+			return false
+		}
 	}
 
-	if !exists {
-		isFormData = false
-	}
+	return true
+}
 
-	switch policyCondition.ConditionUsed {
-	case ConditionMatchingExactMatch:
-		return ExactMatch{elementName: policyCondition.PolicyValue}, isFormData
-	case ConditionMatchingStartWith:
-		return StartWith{ConditionMatchingStartWith, fmt.Sprintf("$%s", elementName), policyCondition.PolicyValue}, isFormData
-	case ConditionSpecifyingRange:
-		return SpecifyingRange{ConditionSpecifyingRange, policyCondition.PolicyStartRange, policyCondition.PolicyStopRange}, isFormData
+func getXAmzMetaSize(xAmzMeta map[string]PolicyConditions) (size int) {
+	for key, value := range xAmzMeta {
+		size += len(strings.TrimPrefix(key, XAmzMetaKey)) + len(value.PolicyValue)
 	}
 
-	return nil, false
+	return size
 }
 
-func getElementPolicy[T PolicyData](elementName string, policyData T) (conditions []interface{}, formValues []FormData) {
-	switch any(policyData).(type) {
-	case map[string]interface{}:
-		var condition []interface{}
-		var formData []FormData
-		if elementName != "x_amz_meta" && elementName != "x_amz" {
-			var policyConditionData PolicyConditions
-			conditionMarshal, _ := json.Marshal(policyData)
-			_ = json.Unmarshal(conditionMarshal, &policyConditionData)
-
-			condition, formData = getElementPolicy(elementName, policyConditionData)
-			if condition == nil {
-				return nil, nil
-			}
-		} else {
-			var policyConditionData map[string]PolicyConditions
-			conditionMarshal, _ := json.Marshal(policyData)
-			_ = json.Unmarshal(conditionMarshal, &policyConditionData)
-
-			condition, formData = getElementPolicy(elementName, policyConditionData)
-		}
+// encode value as RFC 2047 encoded-word, ASCII only value is returned unchanged
+func encodeXAmzMetaValue(value string) string {
+	return mime.BEncoding.Encode("UTF-8", value)
+}
 
-		return condition, formData
-	case PolicyConditions:
-		policyConditionData := any(policyData).(PolicyConditions)
-		conditionStruct, isFormData := conditionFunc(elementName, any(policyData).(PolicyConditions))
-		if conditionStruct == nil {
-			return nil, nil
-		}
+// DecodeXAmzMeta read the user-defined metadata from the object headers (ex: HeadObject response),
+// decoding RFC 2047 encoded values. The returned keys are lowercase and without the x-amz-meta- prefix.
+func DecodeXAmzMeta(header http.Header) (map[string]string, error) {
+	return decodeXAmzMetaValues(header)
+}
 
-		if isFormData {
-			formValues = []FormData{{FormName: elementName, FormValue: policyConditionData.PolicyValue}}
+// decode the x-amz-meta-* values of the headers or the form values
+func decodeXAmzMetaValues(fields map[string][]string) (map[string]string, error) {
+	decoder := new(mime.WordDecoder)
+	xAmzMeta := map[string]string{}
+	for key, values := range fields {
+		lowerKey := strings.ToLower(key)
+		if !strings.HasPrefix(lowerKey, XAmzMetaKey) || len(values) == 0 {
+			continue
 		}
 
-		return []interface{}{conditionStruct}, formValues
-	case map[string]PolicyConditions:
-		policyConditionData := any(policyData).(map[string]PolicyConditions)
-		for idx, value := range policyConditionData {
-			conditionStruct, queryValue := getElementPolicy(idx, value)
-			if conditionStruct == nil {
-				continue
-			}
+		value, err := decoder.DecodeHeader(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode [%s]: %w", key, err)
+		}
 
-			if queryValue != nil && len(queryValue) > 0 {
-				formValues = append(formValues, queryValue...)
-			}
+		xAmzMeta[strings.TrimPrefix(lowerKey, XAmzMetaKey)] = value
+	}
 
-			conditions = append(conditions, conditionStruct...)
-		}
+	return xAmzMeta, nil
+}
 
-		return conditions, formValues
+// check if condition matching is exists and can be used by the policy
+func checkConditions(policyConditions ConditionMatching, conditionMatch string) (canBeUsed bool) {
+	switch conditionMatch {
+	case ConditionMatchingExactMatch:
+		return policyConditions.ExactMatch
+	case ConditionMatchingStartWith:
+		return policyConditions.StartWith
+	case ConditionSpecifyingRange:
+		return policyConditions.SpecifyingRange
 	default:
-		return nil, nil
+		panic("Conditions matching not found!")
 	}
 }
 
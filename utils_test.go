@@ -1,6 +1,7 @@
 package s3Presign
 
 import (
+	"net/http"
 	"testing"
 )
 
@@ -43,3 +44,30 @@ func TestGetCustomKey(t *testing.T) {
 		return
 	}
 }
+
+func TestDecodeXAmzMeta(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Amz-Meta-Uuid", "bc2035bf-72b6-4bad-9e1f-c6c8732ac1a4")
+	header.Set("X-Amz-Meta-Title", "=?UTF-8?b?Y2Fmw6k=?=")
+	header.Set("Content-Type", "image/jpeg")
+
+	xAmzMeta, err := DecodeXAmzMeta(header)
+	if err != nil {
+		t.Fatalf("failed to decode x-amz-meta: %s", err.Error())
+	}
+
+	testResult := map[string]string{
+		"uuid":  "bc2035bf-72b6-4bad-9e1f-c6c8732ac1a4",
+		"title": "café",
+	}
+
+	if len(xAmzMeta) != len(testResult) {
+		t.Errorf("decoded x-amz-meta should have %d values not %d", len(testResult), len(xAmzMeta))
+	}
+
+	for key, value := range testResult {
+		if xAmzMeta[key] != value {
+			t.Errorf("x-amz-meta [%s] should be [%s] not [%s]", key, value, xAmzMeta[key])
+		}
+	}
+}